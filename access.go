@@ -0,0 +1,86 @@
+package gosrvdir
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// IPFilter restricts which client addresses may reach the server.
+type IPFilter struct {
+	Allow      []netip.Prefix
+	Deny       []netip.Prefix
+	BypassAuth bool // allowlisted clients skip authentication
+}
+
+// ParseCIDRs parses a list of CIDR prefixes. Bare IP addresses are
+// accepted and treated as single-host prefixes.
+func ParseCIDRs(list []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q: %w", s, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", s, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Check reports whether a client may proceed and whether it may skip
+// authentication. Denied networks always lose. With BypassAuth set, clients
+// outside the allowlist are not rejected but must authenticate instead.
+func (f *IPFilter) Check(remoteAddr string) (allowed, bypassAuth bool) {
+	addr, ok := clientAddr(remoteAddr)
+	if !ok {
+		return false, false
+	}
+
+	if matchAny(f.Deny, addr) {
+		return false, false
+	}
+
+	if len(f.Allow) == 0 {
+		return true, false
+	}
+
+	if matchAny(f.Allow, addr) {
+		return true, f.BypassAuth
+	}
+
+	return f.BypassAuth, false
+}
+
+func clientAddr(remoteAddr string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func matchAny(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
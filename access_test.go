@@ -0,0 +1,74 @@
+package gosrvdir
+
+import "testing"
+
+func TestIPFilterCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		allow      []string
+		deny       []string
+		bypassAuth bool
+		remote     string
+		allowed    bool
+		bypass     bool
+	}{
+		{"no rules", nil, nil, false, "203.0.113.5:4000", true, false},
+		{"allow-only inside", []string{"10.0.0.0/8"}, nil, false, "10.1.2.3:4000", true, false},
+		{"allow-only outside", []string{"10.0.0.0/8"}, nil, false, "203.0.113.5:4000", false, false},
+		{"allow single host", []string{"192.0.2.7"}, nil, false, "192.0.2.7:4000", true, false},
+		{"allow single host, neighbour", []string{"192.0.2.7"}, nil, false, "192.0.2.8:4000", false, false},
+		{"deny-only inside", nil, []string{"198.51.100.0/24"}, false, "198.51.100.9:4000", false, false},
+		{"deny-only outside", nil, []string{"198.51.100.0/24"}, false, "203.0.113.5:4000", true, false},
+		{"deny wins over allow", []string{"10.0.0.0/8"}, []string{"10.0.5.0/24"}, false, "10.0.5.1:4000", false, false},
+		{"allow around deny", []string{"10.0.0.0/8"}, []string{"10.0.5.0/24"}, false, "10.0.6.1:4000", true, false},
+		{"deny wins over bypass", []string{"10.0.0.0/8"}, []string{"10.0.5.0/24"}, true, "10.0.5.1:4000", false, false},
+		{"IPv4-mapped IPv6 allowed", []string{"10.0.0.0/8"}, nil, false, "[::ffff:10.1.2.3]:4000", true, false},
+		{"IPv4-mapped IPv6 denied", nil, []string{"10.0.0.0/8"}, false, "[::ffff:10.1.2.3]:4000", false, false},
+		{"IPv6 allowed", []string{"2001:db8::/32"}, nil, false, "[2001:db8::1]:4000", true, false},
+		{"IPv6 outside", []string{"2001:db8::/32"}, nil, false, "[2001:db9::1]:4000", false, false},
+		{"bypass inside allowlist", []string{"10.0.0.0/8"}, nil, true, "10.1.2.3:4000", true, true},
+		{"bypass, outside must log in", []string{"10.0.0.0/8"}, nil, true, "203.0.113.5:4000", true, false},
+		{"bypass without allowlist", nil, nil, true, "203.0.113.5:4000", true, false},
+		{"unparsable address", []string{"10.0.0.0/8"}, nil, true, "not-an-ip", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allow, err := ParseCIDRs(tt.allow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			deny, err := ParseCIDRs(tt.deny)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := &IPFilter{Allow: allow, Deny: deny, BypassAuth: tt.bypassAuth}
+
+			allowed, bypass := f.Check(tt.remote)
+			if allowed != tt.allowed || bypass != tt.bypass {
+				t.Errorf("Check(%q) = %v, %v; want %v, %v", tt.remote, allowed, bypass, tt.allowed, tt.bypass)
+			}
+		})
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	prefixes, err := ParseCIDRs([]string{" 10.1.2.3/8 ", "", "::ffff:192.0.2.1", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::1/128"}
+	if len(prefixes) != len(want) {
+		t.Fatalf("got %v, want %v", prefixes, want)
+	}
+	for i, p := range prefixes {
+		if p.String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, p, want[i])
+		}
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "example.com", "10.0.0"} {
+		if _, err := ParseCIDRs([]string{bad}); err == nil {
+			t.Errorf("ParseCIDRs(%q) succeeded", bad)
+		}
+	}
+}
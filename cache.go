@@ -0,0 +1,72 @@
+package gosrvdir
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// listingETag fingerprints everything a listing is rendered from: the
+// directory's mtime (which changes as entries come and go), the shown
+// entries, the directory's settings, the query, the version and, for
+// users who may write, their form token.
+func (h *Handler) listingETag(r *http.Request, info os.FileInfo, entries []FileInfo, settings *dirSettings) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%s\x00%s\x00%d\x00%v\x00", h.Version, r.URL.RawQuery, info.ModTime().UnixNano(), *settings)
+	// Writers get forms with a per-process token
+	if user, ok := h.writeUser(r); ok {
+		fmt.Fprintf(hash, "%s\x00", h.csrf.token(user))
+	}
+	for _, e := range entries {
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\x00%d\x00%s\n", e.Name, e.SizeBytes, e.Files, e.Modified.UnixNano(), e.Size)
+	}
+	// Weak: compressed and identity bodies share the tag
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// listingModTime is the newest of the directory's and its entries' mtimes.
+func listingModTime(info os.FileInfo, entries []FileInfo) time.Time {
+	t := info.ModTime()
+	for _, e := range entries {
+		if e.Modified.After(t) {
+			t = e.Modified
+		}
+	}
+	return t
+}
+
+// checkNotModified sets the validators and answers 304 when the client's
+// copy is current. Only If-None-Match is honoured: the modification time
+// misses changes to parent .gosrvdir files and form tokens, which the
+// ETag covers.
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string, modTime time.Time) bool {
+	modTime = modTime.UTC().Truncate(time.Second)
+	w.Header().Set("ETag", etag)
+	if !modTime.IsZero() {
+		w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if !etagMatch(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatch compares an If-None-Match list weakly against etag.
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package gosrvdir
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckNotModified(t *testing.T) {
+	const etag = `W/"abc"`
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := modTime.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{"no validators", http.MethodGet, nil, false},
+		{"matching etag", http.MethodGet, map[string]string{"If-None-Match": etag}, true},
+		{"strong form of weak etag", http.MethodGet, map[string]string{"If-None-Match": `"abc"`}, true},
+		{"etag in list", http.MethodGet, map[string]string{"If-None-Match": `"x", W/"abc"`}, true},
+		{"wildcard", http.MethodGet, map[string]string{"If-None-Match": "*"}, true},
+		{"other etag", http.MethodGet, map[string]string{"If-None-Match": `W/"old"`}, false},
+		{"If-Modified-Since alone", http.MethodGet, map[string]string{"If-Modified-Since": later}, false},
+		{"stale etag with fresh date", http.MethodGet, map[string]string{"If-None-Match": `W/"old"`, "If-Modified-Since": later}, false},
+		{"HEAD", http.MethodHead, map[string]string{"If-None-Match": etag}, true},
+		{"POST", http.MethodPost, map[string]string{"If-None-Match": etag}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			if got := checkNotModified(rec, r, etag, modTime); got != tt.want {
+				t.Errorf("checkNotModified = %v, want %v", got, tt.want)
+			}
+			if rec.Header().Get("ETag") != etag {
+				t.Errorf("ETag = %q", rec.Header().Get("ETag"))
+			}
+			if rec.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+				t.Errorf("Last-Modified = %q", rec.Header().Get("Last-Modified"))
+			}
+		})
+	}
+}
+
+func TestListingETagFollowsSettings(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"sub/a.txt": "a"})
+	h := &Handler{Dir: dir}
+
+	revalidate := func(header, value string) int {
+		r := httptest.NewRequest(http.MethodGet, "/sub/", nil)
+		r.Header.Set(header, value)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	first := get(h, "/sub/", "")
+	expectStatus(t, first, http.StatusOK)
+	etag := first.Header().Get("ETag")
+	if code := revalidate("If-None-Match", etag); code != http.StatusNotModified {
+		t.Fatalf("unchanged listing: %d", code)
+	}
+
+	// A parent .gosrvdir changes the listing without touching sub/
+	writeTree(t, dir, map[string]string{DirConfigName: "hide = [\"a.txt\"]\n"})
+	if code := revalidate("If-None-Match", etag); code != http.StatusOK {
+		t.Errorf("If-None-Match after settings change: %d", code)
+	}
+	if code := revalidate("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); code != http.StatusOK {
+		t.Errorf("If-Modified-Since after settings change: %d", code)
+	}
+}
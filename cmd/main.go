@@ -1,10 +1,13 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/axelrhd/gosrvdir"
 	"github.com/urfave/cli/v3"
@@ -12,6 +15,13 @@ import (
 
 var appVersion = "dev"
 
+// envPrefix is prepended to the upper-cased flag name, e.g. GOSRVDIR_AUTH_FILE.
+const envPrefix = "GOSRVDIR_"
+
+func env(flag string) cli.ValueSourceChain {
+	return cli.EnvVars(envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_")))
+}
+
 func main() {
 	cmd := &cli.Command{
 		Name:                  "gosrvdir",
@@ -19,29 +29,127 @@ func main() {
 		Version:               appVersion,
 		EnableShellCompletion: true,
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Aliases: []string{"c"},
+				Usage:   "Path to TOML config file (default: $XDG_CONFIG_HOME/gosrvdir/config.toml)",
+				Sources: env("config"),
+			},
 			&cli.IntFlag{
 				Name:    "port",
 				Aliases: []string{"p"},
 				Value:   8080,
 				Usage:   "Port to listen on",
+				Sources: env("port"),
 			},
 			&cli.StringFlag{
-				Name:  "host",
-				Value: "0.0.0.0",
-				Usage: "Host/interface to bind",
+				Name:    "host",
+				Value:   "0.0.0.0",
+				Usage:   "Host/interface to bind",
+				Sources: env("host"),
+			},
+			&cli.StringFlag{
+				Name:    "theme",
+				Value:   "auto",
+				Usage:   "Color theme (auto, nord, squirrel, archlinux, monokai, zenburn)",
+				Sources: env("theme"),
+			},
+			&cli.StringFlag{
+				Name:    "auth",
+				Usage:   "Basic auth credentials (user:password)",
+				Sources: env("auth"),
+			},
+			&cli.StringFlag{
+				Name:    "auth-file",
+				Usage:   "Path to htpasswd file",
+				Sources: env("auth-file"),
+			},
+			&cli.StringSliceFlag{
+				Name:    "allow",
+				Usage:   "Only accept clients from this CIDR (repeatable)",
+				Sources: env("allow"),
+			},
+			&cli.StringSliceFlag{
+				Name:    "deny",
+				Usage:   "Reject clients from this CIDR (repeatable)",
+				Sources: env("deny"),
+			},
+			&cli.BoolFlag{
+				Name:    "allow-bypass-auth",
+				Usage:   "Let --allow networks skip auth; everyone else must log in",
+				Sources: env("allow-bypass-auth"),
+			},
+			&cli.BoolFlag{
+				Name:    "metrics",
+				Usage:   "Expose Prometheus metrics at /_gosrvdir/metrics",
+				Sources: env("metrics"),
+			},
+			&cli.StringFlag{
+				Name:    "metrics-addr",
+				Usage:   "Serve Prometheus metrics on a separate address (e.g. 127.0.0.1:9100)",
+				Sources: env("metrics-addr"),
+			},
+			&cli.BoolFlag{
+				Name:    "render-markdown",
+				Usage:   "Open .md files as rendered pages from the listing",
+				Sources: env("render-markdown"),
+			},
+			&cli.BoolFlag{
+				Name:    "dir-sizes",
+				Usage:   "Compute recursive directory sizes in the background",
+				Sources: env("dir-sizes"),
+			},
+			&cli.BoolFlag{
+				Name:    "index",
+				Usage:   "Serve index.html/index.htm instead of directory listings",
+				Sources: env("index"),
 			},
 			&cli.StringFlag{
-				Name:  "theme",
-				Value: "auto",
-				Usage: "Color theme (auto, nord, squirrel, archlinux, monokai, zenburn)",
+				Name:    "spa",
+				Usage:   "Serve this file (relative to the directory) for missing paths",
+				Sources: env("spa"),
+			},
+			&cli.BoolFlag{
+				Name:    "clean-urls",
+				Usage:   "Serve /about from about.html",
+				Sources: env("clean-urls"),
+			},
+			&cli.BoolFlag{
+				Name:    "livereload",
+				Usage:   "Reload open HTML pages when served files change",
+				Sources: env("livereload"),
+			},
+			&cli.BoolFlag{
+				Name:    "compress",
+				Usage:   "Compress text responses and serve precompressed .gz/.br/.zst files",
+				Sources: env("compress"),
 			},
 			&cli.StringFlag{
-				Name:  "auth",
-				Usage: "Basic auth credentials (user:password)",
+				Name:    "cache-files",
+				Usage:   "Cache-Control header for files, e.g. \"public, max-age=3600\"",
+				Sources: env("cache-files"),
 			},
 			&cli.StringFlag{
-				Name:  "auth-file",
-				Usage: "Path to htpasswd file",
+				Name:    "cache-listings",
+				Usage:   "Cache-Control header for directory listings",
+				Value:   "no-cache",
+				Sources: env("cache-listings"),
+			},
+			&cli.StringSliceFlag{
+				Name:    "write-users",
+				Usage:   "Users allowed to create, rename, move and delete files (repeatable)",
+				Sources: env("write-users"),
+			},
+			&cli.DurationFlag{
+				Name:    "trash-retention",
+				Usage:   "Keep deleted files in the trash this long (0 deletes immediately)",
+				Value:   30 * 24 * time.Hour,
+				Sources: env("trash-retention"),
+			},
+			&cli.BoolFlag{
+				Name:    "webdav",
+				Usage:   "Serve the directory over WebDAV at /_gosrvdir/dav/ (writable for --write-users)",
+				Sources: env("webdav"),
 			},
 		},
 		ArgsUsage: "[directory]",
@@ -57,37 +165,40 @@ func main() {
 					return gosrvdir.RunHtpasswd(cmd.Args().Get(0), cmd.Args().Get(1))
 				},
 			},
+			{
+				Name:  "config",
+				Usage: "Inspect the effective configuration",
+				Commands: []*cli.Command{
+					{
+						Name:      "check",
+						Usage:     "Validate config file, environment and flags",
+						ArgsUsage: "[directory]",
+						Action: func(ctx context.Context, cmd *cli.Command) error {
+							cfg, path, err := buildConfig(cmd)
+							if err != nil {
+								return err
+							}
+							if path == "" {
+								fmt.Println("Config file: none")
+							} else {
+								fmt.Printf("Config file: %s\n", path)
+							}
+							if err := cfg.Validate(); err != nil {
+								return fmt.Errorf("invalid configuration:\n%w", err)
+							}
+							printConfig(cfg)
+							fmt.Println("Configuration OK")
+							return nil
+						},
+					},
+				},
+			},
 		},
 		Action: func(ctx context.Context, cmd *cli.Command) error {
-			auth := cmd.String("auth")
-			authFile := cmd.String("auth-file")
-			if auth != "" && authFile != "" {
-				return fmt.Errorf("--auth and --auth-file are mutually exclusive")
-			}
-
-			dir := "."
-			if cmd.NArg() > 0 {
-				dir = cmd.Args().Get(0)
-			}
-
-			// Validate directory exists
-			info, err := os.Stat(dir)
+			cfg, _, err := buildConfig(cmd)
 			if err != nil {
-				return fmt.Errorf("cannot access directory: %w", err)
-			}
-			if !info.IsDir() {
-				return fmt.Errorf("%s is not a directory", dir)
+				return err
 			}
-
-			cfg := gosrvdir.Config{
-				Host:     cmd.String("host"),
-				Port:     int(cmd.Int("port")),
-				Dir:      dir,
-				Theme:    cmd.String("theme"),
-				Auth:     auth,
-				AuthFile: authFile,
-			}
-
 			return gosrvdir.Serve(cfg)
 		},
 	}
@@ -96,3 +207,126 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// buildConfig merges settings with the precedence
+// flags > GOSRVDIR_* environment > config file > defaults.
+// It returns the config file that was used, if any.
+func buildConfig(cmd *cli.Command) (gosrvdir.Config, string, error) {
+	cfg := gosrvdir.DefaultConfig()
+	cfg.Version = appVersion
+
+	path := cmd.String("config")
+	if path == "" {
+		path = gosrvdir.FindConfigFile()
+	}
+	if path != "" {
+		if err := gosrvdir.LoadConfigFile(path, &cfg); err != nil {
+			return cfg, path, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+
+	// Flags report IsSet for values coming from their env sources, too
+	if cmd.IsSet("host") {
+		cfg.Host = cmd.String("host")
+	}
+	if cmd.IsSet("port") {
+		cfg.Port = int(cmd.Int("port"))
+	}
+	if cmd.IsSet("theme") {
+		cfg.Theme = cmd.String("theme")
+	}
+	if cmd.IsSet("auth") || cmd.IsSet("auth-file") {
+		// Credentials from a higher-precedence source replace the file's
+		cfg.Auth = cmd.String("auth")
+		cfg.AuthFile = cmd.String("auth-file")
+	}
+	if cmd.IsSet("allow") {
+		cfg.Allow = cmd.StringSlice("allow")
+	}
+	if cmd.IsSet("deny") {
+		cfg.Deny = cmd.StringSlice("deny")
+	}
+	if cmd.IsSet("allow-bypass-auth") {
+		cfg.AllowBypassAuth = cmd.Bool("allow-bypass-auth")
+	}
+	if cmd.IsSet("metrics") {
+		cfg.Metrics = cmd.Bool("metrics")
+	}
+	if cmd.IsSet("metrics-addr") {
+		cfg.MetricsAddr = cmd.String("metrics-addr")
+	}
+	if cmd.IsSet("render-markdown") {
+		cfg.RenderMarkdown = cmd.Bool("render-markdown")
+	}
+	if cmd.IsSet("dir-sizes") {
+		cfg.DirSizes = cmd.Bool("dir-sizes")
+	}
+	if cmd.IsSet("index") {
+		cfg.Index = cmd.Bool("index")
+	}
+	if cmd.IsSet("spa") {
+		cfg.SPA = cmd.String("spa")
+	}
+	if cmd.IsSet("clean-urls") {
+		cfg.CleanURLs = cmd.Bool("clean-urls")
+	}
+	if cmd.IsSet("livereload") {
+		cfg.LiveReload = cmd.Bool("livereload")
+	}
+	if cmd.IsSet("compress") {
+		cfg.Compress = cmd.Bool("compress")
+	}
+	if cmd.IsSet("cache-files") {
+		cfg.CacheFiles = cmd.String("cache-files")
+	}
+	if cmd.IsSet("cache-listings") {
+		cfg.CacheListings = cmd.String("cache-listings")
+	}
+	if cmd.IsSet("write-users") {
+		cfg.WriteUsers = cmd.StringSlice("write-users")
+	}
+	if cmd.IsSet("trash-retention") {
+		cfg.TrashRetention = cmd.Duration("trash-retention")
+	}
+	if cmd.IsSet("webdav") {
+		cfg.WebDAV = cmd.Bool("webdav")
+	}
+
+	if dir := os.Getenv(envPrefix + "DIR"); dir != "" {
+		cfg.Dir = dir
+	}
+	if cmd.NArg() > 0 {
+		cfg.Dir = cmd.Args().Get(0)
+	}
+
+	return cfg, path, nil
+}
+
+func printConfig(cfg gosrvdir.Config) {
+	auth := "none"
+	switch {
+	case cfg.Auth != "":
+		user, _, _ := strings.Cut(cfg.Auth, ":")
+		auth = fmt.Sprintf("inline (user %q)", user)
+	case cfg.AuthFile != "":
+		auth = "htpasswd " + cfg.AuthFile
+	}
+
+	fmt.Printf("  dir:     %s\n", cfg.Dir)
+	fmt.Printf("  listen:  %s:%d\n", cfg.Host, cfg.Port)
+	fmt.Printf("  theme:   %s\n", cfg.Theme)
+	fmt.Printf("  auth:    %s\n", auth)
+	if len(cfg.Allow) > 0 || len(cfg.Deny) > 0 {
+		fmt.Printf("  allow:   %s\n", strings.Join(cfg.Allow, ", "))
+		fmt.Printf("  deny:    %s\n", strings.Join(cfg.Deny, ", "))
+	}
+	if cfg.Metrics || cfg.MetricsAddr != "" {
+		fmt.Printf("  metrics: %s\n", cmp.Or(cfg.MetricsAddr, "/_gosrvdir/metrics"))
+	}
+	if len(cfg.WriteUsers) > 0 {
+		fmt.Printf("  write:   %s\n", strings.Join(cfg.WriteUsers, ", "))
+	}
+	if cfg.WebDAV {
+		fmt.Printf("  webdav:  /_gosrvdir/dav/\n")
+	}
+}
@@ -0,0 +1,266 @@
+package gosrvdir
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// compressMinSize skips responses too small to be worth encoding.
+	compressMinSize = 1024
+	// compressMaxSize leaves larger files as they are, so downloads keep
+	// Content-Length for progress and Accept-Ranges for resuming.
+	compressMaxSize = 1 << 20
+)
+
+// encodings in order of preference when the client rates them equally,
+// with the file suffix of precompressed siblings.
+var encodings = []struct {
+	name   string
+	suffix string
+}{
+	{"br", ".br"},
+	{"zstd", ".zst"},
+	{"gzip", ".gz"},
+}
+
+// negotiateEncoding picks the preferred encoding the client accepts among
+// offered, or "" for identity.
+func negotiateEncoding(r *http.Request, offered func(name string) bool) string {
+	header := r.Header.Get("Accept-Encoding")
+	if header == "" {
+		return ""
+	}
+
+	q := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range encodings {
+		weight, ok := q[enc.name]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > bestQ && offered(enc.name) {
+			best, bestQ = enc.name, weight
+		}
+	}
+	return best
+}
+
+// compressible reports whether a Content-Type benefits from compression.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		return false // streamed; encoders would buffer events
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml",
+		"application/wasm", "application/x-ndjson", "application/vnd.apple.mpegurl",
+		"application/x-mpegurl", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// servePrecompressed serves a .br, .zst or .gz sibling of filePath when
+// one exists and the client accepts it. Range requests always get the
+// original so byte offsets stay meaningful.
+func servePrecompressed(w http.ResponseWriter, r *http.Request, filePath string, info os.FileInfo) bool {
+	siblings := make(map[string]string)
+	for _, enc := range encodings {
+		p := filePath + enc.suffix
+		if s, err := os.Stat(p); err == nil && s.Mode().IsRegular() && !s.ModTime().Before(info.ModTime()) {
+			siblings[enc.name] = p
+		}
+	}
+	if len(siblings) == 0 {
+		return false
+	}
+	addVary(w.Header())
+	if r.Header.Get("Range") != "" {
+		return false
+	}
+
+	name := negotiateEncoding(r, func(name string) bool { return siblings[name] != "" })
+	if name == "" {
+		return false
+	}
+	f, err := os.Open(siblings[name])
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	sibling, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	// Not http.ServeContent: it would advertise byte ranges of the
+	// encoded body, which a later Range request would not get.
+	modTime := info.ModTime().UTC().Truncate(time.Second)
+	if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modTime.After(t) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	w.Header().Set("Content-Type", detectMIME(filePath))
+	w.Header().Set("Content-Encoding", name)
+	w.Header().Set("Content-Length", strconv.FormatInt(sibling.Size(), 10))
+	w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		io.Copy(w, f)
+	}
+	return true
+}
+
+// addVary adds Accept-Encoding to the Vary header once.
+func addVary(h http.Header) {
+	for _, v := range h.Values("Vary") {
+		if strings.Contains(strings.ToLower(v), "accept-encoding") {
+			return
+		}
+	}
+	h.Add("Vary", "Accept-Encoding")
+}
+
+var (
+	gzipPool   sync.Pool
+	brotliPool sync.Pool
+	zstdPool   sync.Pool
+)
+
+// encoder wraps w in a pooled encoder; release returns it to the pool.
+func encoder(name string, w io.Writer) (enc io.WriteCloser, release func()) {
+	switch name {
+	case "br":
+		bw, _ := brotliPool.Get().(*brotli.Writer)
+		if bw == nil {
+			bw = brotli.NewWriterLevel(w, 4)
+		} else {
+			bw.Reset(w)
+		}
+		return bw, func() { brotliPool.Put(bw) }
+	case "zstd":
+		zw, _ := zstdPool.Get().(*zstd.Encoder)
+		if zw == nil {
+			zw, _ = zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		} else {
+			zw.Reset(w)
+		}
+		return zw, func() { zstdPool.Put(zw) }
+	default:
+		gw, _ := gzipPool.Get().(*gzip.Writer)
+		if gw == nil {
+			gw, _ = gzip.NewWriterLevel(w, 5)
+		} else {
+			gw.Reset(w)
+		}
+		return gw, func() { gzipPool.Put(gw) }
+	}
+}
+
+// compressWriter encodes compressible 200 responses on the fly. The
+// decision is made at WriteHeader, once the handler has set its headers.
+type compressWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	decided bool
+	enc     io.WriteCloser
+	release func()
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if !c.decided {
+		c.decided = true
+		c.decide(code)
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *compressWriter) decide(code int) {
+	h := c.Header()
+	if h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")) {
+		return
+	}
+	addVary(h)
+
+	// Partial content and small or large bodies stay as they are
+	if code != http.StatusOK || c.r.Header.Get("Range") != "" || c.r.Method == http.MethodHead {
+		return
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && (n < compressMinSize || n > compressMaxSize) {
+		return
+	}
+
+	name := negotiateEncoding(c.r, func(string) bool { return true })
+	if name == "" {
+		return
+	}
+	h.Set("Content-Encoding", name)
+	h.Del("Content-Length")
+	h.Del("Accept-Ranges")
+	c.enc, c.release = encoder(name, c.ResponseWriter)
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.decided {
+		if c.Header().Get("Content-Type") == "" {
+			c.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.enc != nil {
+		return c.enc.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *compressWriter) Flush() {
+	if f, ok := c.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Close finishes the encoded stream, if any.
+func (c *compressWriter) Close() {
+	if c.enc != nil {
+		c.enc.Close()
+		c.release()
+		c.enc = nil
+	}
+}
@@ -0,0 +1,153 @@
+package gosrvdir
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ConfigFileName is the file looked up in the user config directory.
+const ConfigFileName = "config.toml"
+
+// DefaultConfig returns the settings used when neither a config file,
+// environment variables nor flags say otherwise.
+func DefaultConfig() Config {
+	return Config{
+		Host:  "0.0.0.0",
+		Port:  8080,
+		Dir:   ".",
+		Theme: "auto",
+
+		CacheListings: "no-cache",
+
+		TrashRetention: 30 * 24 * time.Hour,
+	}
+}
+
+// FindConfigFile returns the auto-discovered config file
+// ($XDG_CONFIG_HOME/gosrvdir/config.toml), or "" if there is none.
+func FindConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(dir, "gosrvdir", ConfigFileName)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// LoadConfigFile decodes a TOML config file on top of cfg. Unknown keys are
+// rejected, and relative paths are resolved against the file's directory.
+func LoadConfigFile(path string, cfg *Config) error {
+	md, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		return err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, k := range undecoded {
+			keys[i] = k.String()
+		}
+		return fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+	}
+
+	base := filepath.Dir(path)
+	if md.IsDefined("dir") {
+		cfg.Dir = resolvePath(base, cfg.Dir)
+	}
+	if md.IsDefined("auth_file") {
+		cfg.AuthFile = resolvePath(base, cfg.AuthFile)
+	}
+	return nil
+}
+
+func resolvePath(base, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+	return filepath.Join(base, p)
+}
+
+// Validate checks the configuration without starting the server.
+func (cfg Config) Validate() error {
+	var errs []error
+
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d out of range", cfg.Port))
+	}
+
+	if info, err := os.Stat(cfg.Dir); err != nil {
+		errs = append(errs, fmt.Errorf("cannot access directory: %w", err))
+	} else if !info.IsDir() {
+		errs = append(errs, fmt.Errorf("%s is not a directory", cfg.Dir))
+	}
+
+	if !slices.ContainsFunc(themes, func(t theme) bool { return t.value == cfg.Theme }) {
+		errs = append(errs, fmt.Errorf("unknown theme %q", cfg.Theme))
+	}
+
+	if cfg.Auth != "" && cfg.AuthFile != "" {
+		errs = append(errs, fmt.Errorf("auth and auth-file are mutually exclusive"))
+	}
+	if cfg.Auth != "" && !strings.Contains(cfg.Auth, ":") {
+		errs = append(errs, fmt.Errorf("invalid auth format, expected user:password"))
+	}
+	if cfg.AuthFile != "" {
+		if _, err := ParseHtpasswd(cfg.AuthFile); err != nil {
+			errs = append(errs, fmt.Errorf("reading auth file: %w", err))
+		}
+	}
+
+	if _, err := ParseCIDRs(cfg.Allow); err != nil {
+		errs = append(errs, fmt.Errorf("allow: %w", err))
+	}
+	if _, err := ParseCIDRs(cfg.Deny); err != nil {
+		errs = append(errs, fmt.Errorf("deny: %w", err))
+	}
+	if cfg.AllowBypassAuth {
+		if len(cfg.Allow) == 0 {
+			errs = append(errs, fmt.Errorf("allow-bypass-auth requires allow"))
+		}
+		if cfg.Auth == "" && cfg.AuthFile == "" {
+			errs = append(errs, fmt.Errorf("allow-bypass-auth requires auth or auth-file"))
+		}
+	}
+
+	if len(cfg.WriteUsers) > 0 {
+		if cfg.Auth == "" && cfg.AuthFile == "" {
+			errs = append(errs, fmt.Errorf("write-users requires auth or auth-file"))
+		}
+		if user, _, _ := strings.Cut(cfg.Auth, ":"); cfg.Auth != "" && !slices.Equal(cfg.WriteUsers, []string{user}) {
+			errs = append(errs, fmt.Errorf("write-users: only %q can log in with auth", user))
+		}
+	}
+
+	if cfg.TrashRetention < 0 {
+		errs = append(errs, fmt.Errorf("trash-retention must not be negative"))
+	}
+
+	if cfg.SPA != "" {
+		if !filepath.IsLocal(cfg.SPA) {
+			errs = append(errs, fmt.Errorf("spa %q must be a path inside the served directory", cfg.SPA))
+		} else if info, err := os.Stat(filepath.Join(cfg.Dir, cfg.SPA)); err != nil {
+			errs = append(errs, fmt.Errorf("spa: %w", err))
+		} else if !info.Mode().IsRegular() {
+			errs = append(errs, fmt.Errorf("spa %s is not a file", cfg.SPA))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,207 @@
+package gosrvdir
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxTableRows = 1000    // rows shown by the CSV/TSV viewer
+	maxJSONSize  = 2 << 20 // larger JSON files are served raw
+)
+
+func isDelimited(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".tsv":
+		return true
+	}
+	return false
+}
+
+func isJSON(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".json")
+}
+
+func (h *Handler) serveTable(w http.ResponseWriter, r *http.Request, filePath, urlPath string, info os.FileInfo, settings *dirSettings) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		http.Error(w, "Cannot read file", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	if strings.EqualFold(filepath.Ext(filePath), ".tsv") {
+		cr.Comma = '\t'
+	}
+
+	// Read one row past the limit to know whether the table was cut
+	var rows [][]string
+	for len(rows) <= maxTableRows {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if len(rows) == 0 {
+				h.serveFile(w, r, filePath)
+				return
+			}
+			break
+		}
+		rows = append(rows, row)
+	}
+
+	data := TableData{
+		Path:  urlPath,
+		Theme: settings.Theme,
+		Size:  formatSize(info.Size()),
+	}
+	if len(rows) > maxTableRows {
+		rows = rows[:maxTableRows]
+		data.Truncated = true
+	}
+	if hasHeader(rows) {
+		data.Header, rows = rows[0], rows[1:]
+	}
+	data.Rows = rows
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	TablePage(data).Render(w)
+}
+
+// hasHeader guesses whether the first row holds column names: none of its
+// cells are numeric while the same column is numeric further down, or,
+// failing that, its cells are non-empty and distinct.
+func hasHeader(rows [][]string) bool {
+	if len(rows) < 2 {
+		return false
+	}
+
+	first := rows[0]
+	for _, cell := range first {
+		if isNumeric(cell) {
+			return false
+		}
+	}
+	for i := range first {
+		if i < len(rows[1]) && isNumeric(rows[1][i]) {
+			return true
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, cell := range first {
+		cell = strings.TrimSpace(cell)
+		if cell == "" || seen[cell] {
+			return false
+		}
+		seen[cell] = true
+	}
+	return true
+}
+
+func isNumeric(s string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return err == nil
+}
+
+func (h *Handler) serveJSONTree(w http.ResponseWriter, r *http.Request, filePath, urlPath string, info os.FileInfo, settings *dirSettings) {
+	if info.Size() > maxJSONSize {
+		h.serveFile(w, r, filePath)
+		return
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		http.Error(w, "Cannot read file", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	root, err := decodeJSONValue(dec)
+	if err != nil {
+		h.serveFile(w, r, filePath)
+		return
+	}
+
+	data := JSONData{
+		Path:  urlPath,
+		Theme: settings.Theme,
+		Size:  formatSize(info.Size()),
+		Root:  root,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	JSONPage(data).Render(w)
+}
+
+// JSONValue is a decoded JSON value that keeps object keys in file order.
+type JSONValue struct {
+	Kind   string // object, array, string, number, bool, null
+	Scalar string
+	Keys   []string // object keys, parallel to Items
+	Items  []JSONValue
+}
+
+func decodeJSONValue(dec *json.Decoder) (JSONValue, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return JSONValue{}, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			v := JSONValue{Kind: "object"}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return v, err
+				}
+				item, err := decodeJSONValue(dec)
+				if err != nil {
+					return v, err
+				}
+				v.Keys = append(v.Keys, key.(string))
+				v.Items = append(v.Items, item)
+			}
+			_, err := dec.Token() // closing }
+			return v, err
+		case '[':
+			v := JSONValue{Kind: "array"}
+			for dec.More() {
+				item, err := decodeJSONValue(dec)
+				if err != nil {
+					return v, err
+				}
+				v.Items = append(v.Items, item)
+			}
+			_, err := dec.Token() // closing ]
+			return v, err
+		}
+		return JSONValue{}, fmt.Errorf("unexpected delimiter %v", t)
+	case string:
+		return JSONValue{Kind: "string", Scalar: strconv.Quote(t)}, nil
+	case json.Number:
+		return JSONValue{Kind: "number", Scalar: t.String()}, nil
+	case bool:
+		return JSONValue{Kind: "bool", Scalar: strconv.FormatBool(t)}, nil
+	case nil:
+		return JSONValue{Kind: "null", Scalar: "null"}, nil
+	}
+	return JSONValue{}, fmt.Errorf("unexpected token %v", tok)
+}
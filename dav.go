@@ -0,0 +1,245 @@
+package gosrvdir
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/webdav"
+)
+
+// davPrefix is where WebDAV clients mount Dir.
+const davPrefix = reservedPrefix + "dav/"
+
+// davLocks holds the WebDAV locks. The zero value is ready to use.
+type davLocks struct {
+	once sync.Once
+	ls   webdav.LockSystem
+}
+
+func (l *davLocks) system() webdav.LockSystem {
+	l.once.Do(func() { l.ls = webdav.NewMemLS() })
+	return l.ls
+}
+
+// serveDAV serves Dir over WebDAV with the credentials, ignore and hide
+// rules of the listing. Only WriteUsers may change anything.
+func (h *Handler) serveDAV(w http.ResponseWriter, r *http.Request, bypassAuth bool) {
+	urlPath := path.Clean("/" + strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(davPrefix, "/")))
+	settings, _, err := h.settingsFor(urlPath)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	r, ok := h.authenticate(w, r, settings.Creds, bypassAuth)
+	if !ok {
+		return
+	}
+
+	user, writable := h.writeUser(r)
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+	default:
+		if !writable || !sameOrigin(r) {
+			http.Error(w, "Write access denied", http.StatusForbidden)
+			return
+		}
+	}
+
+	dav := &webdav.Handler{
+		Prefix:     strings.TrimSuffix(davPrefix, "/"),
+		FileSystem: &davFS{h: h, creds: settings.Creds, bypass: bypassAuth, user: user, writable: writable},
+		LockSystem: h.locks.system(),
+		Logger: func(r *http.Request, err error) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND", "LOCK", "UNLOCK":
+			default:
+				if err != nil {
+					log.Printf("dav %s by %s: %s: %v", r.Method, user, r.URL.Path, err)
+				} else {
+					log.Printf("%s: dav %s %s", user, r.Method, r.URL.Path)
+				}
+			}
+		},
+	}
+	dav.ServeHTTP(w, r)
+}
+
+// davFS is webdav.Dir confined to what the request's credentials cover.
+// Ignored entries do not exist, hidden ones are left out of directory
+// listings, and subtrees with other credentials appear in their parent
+// but cannot be opened until the client mounts them with those.
+type davFS struct {
+	h        *Handler
+	creds    Credentials
+	bypass   bool // the client skipped auth and sees everything readable
+	user     string
+	writable bool
+}
+
+// resolve checks that name exists as far as the listing is concerned and
+// is covered by the request's credentials, returning its path on disk.
+// Through symlinks, the credentials are those of where name really is.
+// With peek, a directory with other credentials may also be looked at
+// (but not listed) when its parent is covered.
+func (d *davFS) resolve(op, name string, peek bool) (string, *dirSettings, error) {
+	name = path.Clean("/" + name)
+	settings, found, err := d.h.settingsFor(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if !found {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	p := filepath.Join(d.h.Dir, filepath.FromSlash(name))
+	if d.bypass || sameCreds(settings.Creds, d.creds) {
+		return p, &settings, nil
+	}
+
+	if peek && name != "/" {
+		parent, _, err := d.h.settingsFor(path.Dir(name))
+		if err != nil {
+			return "", nil, err
+		}
+		if info, err := os.Stat(p); err == nil && info.IsDir() && sameCreds(parent.Creds, d.creds) {
+			return p, &settings, nil
+		}
+	}
+	return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+}
+
+// resolveWrite is resolve for changes: the user must be allowed to write,
+// the entry's directory must be below Dir after resolving symlinks, and
+// directories must not contain subtrees with other credentials.
+func (d *davFS) resolveWrite(op, name string) (string, error) {
+	if !d.writable {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	p, settings, err := d.resolve(op, name, false)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Ignored names cannot be created either
+			err = &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+		}
+		return "", err
+	}
+	if !d.h.insideRoot(filepath.Dir(p)) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	if info, err := os.Lstat(p); err == nil && info.IsDir() && !d.h.sameCredsBelow(p, path.Clean("/"+name), settings) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return p, nil
+}
+
+func (d *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	p, err := d.resolveWrite("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(p, perm)
+}
+
+func (d *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		p, err := d.resolveWrite("open", name)
+		if err != nil {
+			return nil, err
+		}
+		// Writing through a symlink would change what it points to
+		if !d.h.insideRoot(p) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+		}
+		return os.OpenFile(p, flag, perm)
+	}
+
+	p, settings, err := d.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !d.h.insideRoot(p) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	return &davFile{File: f, fs: d, settings: settings}, nil
+}
+
+func (d *davFS) RemoveAll(ctx context.Context, name string) error {
+	p, err := d.resolveWrite("remove", name)
+	if err != nil {
+		return err
+	}
+	if p == filepath.Clean(d.h.Dir) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	if d.h.TrashRetention > 0 {
+		if _, err := os.Lstat(p); err != nil {
+			return err
+		}
+		return d.h.moveToTrash(p, path.Clean("/"+name), d.user)
+	}
+	return os.RemoveAll(p)
+}
+
+func (d *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	src, err := d.resolveWrite("rename", oldName)
+	if err != nil {
+		return err
+	}
+	dst, err := d.resolveWrite("rename", newName)
+	if err != nil {
+		return err
+	}
+	if src == filepath.Clean(d.h.Dir) {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrPermission}
+	}
+	return os.Rename(src, dst)
+}
+
+func (d *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	p, _, err := d.resolve("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if d.h.insideRoot(p) {
+		return os.Stat(p)
+	}
+	// A symlink leading out of Dir is shown as the link, so that it can
+	// still be deleted, but nothing behind it is
+	if !d.h.insideRoot(filepath.Dir(p)) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrPermission}
+	}
+	return os.Lstat(p)
+}
+
+// davFile leaves hidden entries out of directory listings and refuses
+// to list directories with other credentials.
+type davFile struct {
+	*os.File
+	fs       *davFS
+	settings *dirSettings
+}
+
+func (f *davFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if !f.fs.bypass && !sameCreds(f.settings.Creds, f.fs.creds) {
+		return nil, &fs.PathError{Op: "readdir", Path: f.Name(), Err: fs.ErrPermission}
+	}
+	infos, err := f.File.Readdir(count)
+	visible := infos[:0]
+	for _, info := range infos {
+		if !f.settings.hidden(info.Name()) {
+			visible = append(visible, info)
+		}
+	}
+	return visible, err
+}
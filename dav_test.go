@@ -0,0 +1,481 @@
+package gosrvdir
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// davTree serves a directory laid out like this, with alice allowed to
+// write, bob only to read and carol the sole user of priv/ and box/inner/:
+//
+//	a.txt, .secret (hidden), skip.tmp (ignored), sub/b.txt,
+//	priv/p.txt, box/inner/i.txt, link -> a directory outside
+func davTree(t *testing.T) (*Handler, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	outside := t.TempDir()
+
+	carol := "carol:" + hashPassword(t, "pw") + "\n"
+	writeTree(t, dir, map[string]string{
+		"a.txt":                      "hello",
+		".secret":                    "secret",
+		"skip.tmp":                   "ignored",
+		DirConfigName:                "hide = [\".secret\"]\nignore = [\"*.tmp\"]\n",
+		"sub/b.txt":                  "b",
+		"priv/p.txt":                 "private",
+		"priv/.htpasswd":             carol,
+		"priv/" + DirConfigName:      "auth_file = \".htpasswd\"\n",
+		"box/inner/i.txt":            "inner",
+		"box/inner/.htpasswd":        carol,
+		"box/inner/" + DirConfigName: "auth_file = \".htpasswd\"\n",
+	})
+	writeTree(t, outside, map[string]string{"out.txt": "outside"})
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{
+		Dir: dir,
+		Creds: Credentials{
+			"alice": hashPassword(t, "pw"),
+			"bob":   hashPassword(t, "pw"),
+		},
+		WriteUsers:     []string{"alice"},
+		TrashRetention: time.Hour,
+		WebDAV:         true,
+	}
+	return h, dir, outside
+}
+
+func hashPassword(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+// dav sends a WebDAV request for a path below the mount as user ("" for
+// none), with headers given as name/value pairs.
+func dav(h *Handler, method, p, user, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, strings.TrimSuffix(davPrefix, "/")+p, strings.NewReader(body))
+	if user != "" {
+		r.SetBasicAuth(user, "pw")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, code int) {
+	t.Helper()
+	if rec.Code != code {
+		t.Fatalf("status %d, want %d: %s", rec.Code, code, rec.Body.String())
+	}
+}
+
+func expectFile(t *testing.T, p, content string) {
+	t.Helper()
+	got, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Fatalf("%s = %q, want %q", p, got, content)
+	}
+}
+
+func expectMissing(t *testing.T, p string) {
+	t.Helper()
+	if _, err := os.Lstat(p); err == nil {
+		t.Fatalf("%s exists", p)
+	}
+}
+
+func TestDAVOptions(t *testing.T) {
+	h, _, _ := davTree(t)
+
+	rec := dav(h, http.MethodOptions, "/", "bob", "")
+	expectStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("DAV"); !strings.Contains(got, "1") || !strings.Contains(got, "2") {
+		t.Errorf("DAV header %q, want classes 1 and 2", got)
+	}
+	if !strings.Contains(rec.Header().Get("Allow"), "PROPFIND") {
+		t.Errorf("Allow %q lacks PROPFIND", rec.Header().Get("Allow"))
+	}
+
+	expectStatus(t, dav(h, http.MethodOptions, "/", "", ""), http.StatusUnauthorized)
+}
+
+func TestDAVPropfind(t *testing.T) {
+	h, _, _ := davTree(t)
+	const mount = "/_gosrvdir/dav/"
+
+	tests := []struct {
+		depth   string
+		want    []string
+		notWant []string
+	}{
+		{"0", []string{mount}, []string{mount + "a.txt"}},
+		{"1",
+			[]string{mount, mount + "a.txt", mount + "sub/", mount + "priv/", mount + "box/"},
+			[]string{mount + "sub/b.txt", mount + ".secret", mount + "skip.tmp", mount + DirConfigName, mount + TrashName}},
+		{"infinity",
+			[]string{mount + "sub/b.txt", mount + "box/inner/"},
+			[]string{mount + "priv/p.txt", mount + "box/inner/i.txt", mount + ".secret", mount + "skip.tmp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.depth, func(t *testing.T) {
+			rec := dav(h, "PROPFIND", "/", "bob", "", "Depth", tt.depth)
+			expectStatus(t, rec, http.StatusMultiStatus)
+			body := rec.Body.String()
+			for _, href := range tt.want {
+				if !strings.Contains(body, "<D:href>"+href+"</D:href>") {
+					t.Errorf("missing %s", href)
+				}
+			}
+			for _, href := range tt.notWant {
+				if strings.Contains(body, "<D:href>"+href+"</D:href>") {
+					t.Errorf("unexpected %s", href)
+				}
+			}
+		})
+	}
+}
+
+func TestDAVPutGet(t *testing.T) {
+	h, dir, _ := davTree(t)
+
+	expectStatus(t, dav(h, http.MethodPut, "/new.txt", "alice", "fresh"), http.StatusCreated)
+	expectFile(t, filepath.Join(dir, "new.txt"), "fresh")
+	expectStatus(t, dav(h, http.MethodPut, "/new.txt", "alice", "again"), http.StatusCreated)
+	expectFile(t, filepath.Join(dir, "new.txt"), "again")
+
+	rec := dav(h, http.MethodGet, "/new.txt", "bob", "")
+	expectStatus(t, rec, http.StatusOK)
+	if body, _ := io.ReadAll(rec.Body); string(body) != "again" {
+		t.Errorf("GET = %q", body)
+	}
+	expectStatus(t, dav(h, http.MethodGet, "/missing.txt", "bob", ""), http.StatusNotFound)
+}
+
+func TestDAVMkcol(t *testing.T) {
+	h, dir, _ := davTree(t)
+
+	expectStatus(t, dav(h, "MKCOL", "/made", "alice", ""), http.StatusCreated)
+	if info, err := os.Stat(filepath.Join(dir, "made")); err != nil || !info.IsDir() {
+		t.Fatalf("made: %v", err)
+	}
+	expectStatus(t, dav(h, "MKCOL", "/made", "alice", ""), http.StatusMethodNotAllowed)
+	expectStatus(t, dav(h, "MKCOL", "/nope/deeper", "alice", ""), http.StatusConflict)
+}
+
+func TestDAVCopy(t *testing.T) {
+	h, dir, _ := davTree(t)
+	dest := "http://example.com/_gosrvdir/dav/sub/a.txt"
+
+	expectStatus(t, dav(h, "COPY", "/a.txt", "alice", "", "Destination", dest), http.StatusCreated)
+	expectFile(t, filepath.Join(dir, "sub", "a.txt"), "hello")
+
+	expectStatus(t, dav(h, "COPY", "/sub/b.txt", "alice", "", "Destination", dest, "Overwrite", "F"), http.StatusPreconditionFailed)
+	expectFile(t, filepath.Join(dir, "sub", "a.txt"), "hello")
+
+	expectStatus(t, dav(h, "COPY", "/sub/b.txt", "alice", "", "Destination", dest, "Overwrite", "T"), http.StatusNoContent)
+	expectFile(t, filepath.Join(dir, "sub", "a.txt"), "b")
+
+	expectStatus(t, dav(h, "COPY", "/sub", "alice", "", "Destination", "http://example.com/_gosrvdir/dav/sub2"), http.StatusCreated)
+	expectFile(t, filepath.Join(dir, "sub2", "b.txt"), "b")
+}
+
+func TestDAVMove(t *testing.T) {
+	h, dir, _ := davTree(t)
+	dest := "http://example.com/_gosrvdir/dav/moved.txt"
+
+	expectStatus(t, dav(h, "MOVE", "/sub/b.txt", "alice", "", "Destination", dest), http.StatusCreated)
+	expectMissing(t, filepath.Join(dir, "sub", "b.txt"))
+	expectFile(t, filepath.Join(dir, "moved.txt"), "b")
+
+	expectStatus(t, dav(h, "MOVE", "/a.txt", "alice", "", "Destination", dest, "Overwrite", "F"), http.StatusPreconditionFailed)
+	expectFile(t, filepath.Join(dir, "a.txt"), "hello")
+
+	expectStatus(t, dav(h, "MOVE", "/a.txt", "alice", "", "Destination", dest, "Overwrite", "T"), http.StatusNoContent)
+	expectMissing(t, filepath.Join(dir, "a.txt"))
+	expectFile(t, filepath.Join(dir, "moved.txt"), "hello")
+
+	// The replaced file went to the trash
+	items, err := h.trashItems()
+	if err != nil || len(items) != 1 || items[0].Path != "/moved.txt" || items[0].User != "alice" {
+		t.Fatalf("trash = %+v, %v", items, err)
+	}
+}
+
+func TestDAVDelete(t *testing.T) {
+	h, dir, _ := davTree(t)
+
+	expectStatus(t, dav(h, http.MethodDelete, "/sub", "alice", ""), http.StatusNoContent)
+	expectMissing(t, filepath.Join(dir, "sub"))
+	items, err := h.trashItems()
+	if err != nil || len(items) != 1 || items[0].Path != "/sub" || !items[0].IsDir {
+		t.Fatalf("trash = %+v, %v", items, err)
+	}
+	if err := h.restoreTrash(items[0]); err != nil {
+		t.Fatal(err)
+	}
+	expectFile(t, filepath.Join(dir, "sub", "b.txt"), "b")
+
+	// The trash itself is out of reach
+	expectStatus(t, dav(h, "PROPFIND", "/"+TrashName, "alice", "", "Depth", "0"), http.StatusNotFound)
+
+	h.TrashRetention = 0
+	expectStatus(t, dav(h, http.MethodDelete, "/a.txt", "alice", ""), http.StatusNoContent)
+	expectMissing(t, filepath.Join(dir, "a.txt"))
+	if items, _ := h.trashItems(); len(items) != 0 {
+		t.Errorf("trash = %+v, want empty", items)
+	}
+
+	expectStatus(t, dav(h, http.MethodDelete, "/", "alice", ""), http.StatusMethodNotAllowed)
+	expectStatus(t, dav(h, http.MethodDelete, "/missing", "alice", ""), http.StatusNotFound)
+}
+
+func TestDAVLock(t *testing.T) {
+	h, dir, _ := davTree(t)
+	const lockinfo = `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>`
+
+	rec := dav(h, "LOCK", "/a.txt", "alice", lockinfo, "Timeout", "Second-60")
+	expectStatus(t, rec, http.StatusOK)
+	token := rec.Header().Get("Lock-Token")
+	if token == "" {
+		t.Fatal("no Lock-Token")
+	}
+
+	expectStatus(t, dav(h, http.MethodPut, "/a.txt", "alice", "no token"), http.StatusLocked)
+	expectStatus(t, dav(h, "LOCK", "/a.txt", "alice", lockinfo), http.StatusLocked)
+	expectStatus(t, dav(h, http.MethodPut, "/a.txt", "alice", "with token", "If", "("+token+")"), http.StatusCreated)
+	expectFile(t, filepath.Join(dir, "a.txt"), "with token")
+
+	expectStatus(t, dav(h, "UNLOCK", "/a.txt", "alice", "", "Lock-Token", token), http.StatusNoContent)
+	expectStatus(t, dav(h, http.MethodPut, "/a.txt", "alice", "unlocked"), http.StatusCreated)
+	expectFile(t, filepath.Join(dir, "a.txt"), "unlocked")
+}
+
+func TestDAVReadOnly(t *testing.T) {
+	h, dir, _ := davTree(t)
+	dest := "http://example.com/_gosrvdir/dav/copy.txt"
+
+	tests := []struct {
+		method  string
+		p       string
+		body    string
+		headers []string
+	}{
+		{http.MethodPut, "/a.txt", "changed", nil},
+		{http.MethodPut, "/new.txt", "new", nil},
+		{"MKCOL", "/made", "", nil},
+		{http.MethodDelete, "/a.txt", "", nil},
+		{"COPY", "/a.txt", "", []string{"Destination", dest}},
+		{"MOVE", "/a.txt", "", []string{"Destination", dest}},
+		{"PROPPATCH", "/a.txt", "", nil},
+		{"LOCK", "/a.txt", "", nil},
+		{http.MethodPost, "/a.txt", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.p, func(t *testing.T) {
+			expectStatus(t, dav(h, tt.method, tt.p, "bob", tt.body, tt.headers...), http.StatusForbidden)
+		})
+	}
+
+	// Nor may writers from other sites, or writers whose auth was skipped
+	expectStatus(t, dav(h, "MKCOL", "/made", "alice", "", "Origin", "http://evil.example"), http.StatusForbidden)
+
+	expectFile(t, filepath.Join(dir, "a.txt"), "hello")
+	expectMissing(t, filepath.Join(dir, "new.txt"))
+	expectMissing(t, filepath.Join(dir, "made"))
+	expectMissing(t, filepath.Join(dir, "copy.txt"))
+	expectStatus(t, dav(h, http.MethodGet, "/a.txt", "bob", ""), http.StatusOK)
+}
+
+func TestDAVBypassAuthReadOnly(t *testing.T) {
+	h, dir, _ := davTree(t)
+	// httptest requests come from 192.0.2.1
+	h.Filter = &IPFilter{Allow: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}, BypassAuth: true}
+
+	expectStatus(t, dav(h, http.MethodGet, "/priv/p.txt", "", ""), http.StatusOK)
+	expectStatus(t, dav(h, http.MethodPut, "/new.txt", "", "new"), http.StatusForbidden)
+	expectMissing(t, filepath.Join(dir, "new.txt"))
+}
+
+func TestDAVIgnoredAndHidden(t *testing.T) {
+	h, dir, _ := davTree(t)
+
+	// Ignored entries do not exist, hidden ones can be opened by name
+	expectStatus(t, dav(h, http.MethodGet, "/skip.tmp", "bob", ""), http.StatusNotFound)
+	expectStatus(t, dav(h, http.MethodGet, "/"+DirConfigName, "bob", ""), http.StatusNotFound)
+	expectStatus(t, dav(h, "PROPFIND", "/skip.tmp", "bob", "", "Depth", "0"), http.StatusNotFound)
+	expectStatus(t, dav(h, http.MethodGet, "/.secret", "bob", ""), http.StatusOK)
+
+	// and cannot be written, created, replaced or deleted
+	for _, p := range []string{"/skip.tmp", "/new.tmp", "/" + DirConfigName, "/sub/" + DirConfigName, "/" + TrashName + "/x"} {
+		if rec := dav(h, http.MethodPut, p, "alice", "x"); rec.Code < 400 {
+			t.Errorf("PUT %s: %d", p, rec.Code)
+		}
+	}
+	if rec := dav(h, http.MethodDelete, "/skip.tmp", "alice", ""); rec.Code < 400 {
+		t.Errorf("DELETE /skip.tmp: %d", rec.Code)
+	}
+	if rec := dav(h, "MOVE", "/a.txt", "alice", "", "Destination", "http://example.com/_gosrvdir/dav/a.tmp"); rec.Code < 400 {
+		t.Errorf("MOVE to ignored name: %d", rec.Code)
+	}
+	expectFile(t, filepath.Join(dir, "skip.tmp"), "ignored")
+	expectFile(t, filepath.Join(dir, "a.txt"), "hello")
+	expectMissing(t, filepath.Join(dir, "new.tmp"))
+	expectMissing(t, filepath.Join(dir, "sub", DirConfigName))
+}
+
+func TestDAVOtherCredentials(t *testing.T) {
+	h, dir, _ := davTree(t)
+
+	// priv/ is carol's alone
+	expectStatus(t, dav(h, http.MethodGet, "/priv/p.txt", "alice", ""), http.StatusUnauthorized)
+	expectStatus(t, dav(h, "PROPFIND", "/priv/", "bob", "", "Depth", "1"), http.StatusUnauthorized)
+	expectStatus(t, dav(h, http.MethodGet, "/priv/p.txt", "carol", ""), http.StatusOK)
+
+	rec := dav(h, "PROPFIND", "/priv/", "carol", "", "Depth", "1")
+	expectStatus(t, rec, http.StatusMultiStatus)
+	if !strings.Contains(rec.Body.String(), "/_gosrvdir/dav/priv/p.txt") {
+		t.Errorf("carol's listing lacks p.txt: %s", rec.Body.String())
+	}
+
+	// Root users cannot change it from outside, nor what contains such a subtree
+	if rec := dav(h, "MOVE", "/a.txt", "alice", "", "Destination", "http://example.com/_gosrvdir/dav/priv/a.txt"); rec.Code < 400 {
+		t.Errorf("MOVE into priv: %d", rec.Code)
+	}
+	if rec := dav(h, "COPY", "/a.txt", "alice", "", "Destination", "http://example.com/_gosrvdir/dav/priv/a.txt"); rec.Code < 400 {
+		t.Errorf("COPY into priv: %d", rec.Code)
+	}
+	if rec := dav(h, http.MethodDelete, "/box", "alice", ""); rec.Code < 400 {
+		t.Errorf("DELETE box: %d", rec.Code)
+	}
+	if rec := dav(h, "MOVE", "/box", "alice", "", "Destination", "http://example.com/_gosrvdir/dav/box2"); rec.Code < 400 {
+		t.Errorf("MOVE box: %d", rec.Code)
+	}
+	expectMissing(t, filepath.Join(dir, "priv", "a.txt"))
+	expectFile(t, filepath.Join(dir, "box", "inner", "i.txt"), "inner")
+	expectFile(t, filepath.Join(dir, "a.txt"), "hello")
+}
+
+func TestDAVSymlinkEscape(t *testing.T) {
+	h, dir, outside := davTree(t)
+
+	if rec := dav(h, http.MethodPut, "/link/viadav.txt", "alice", "x"); rec.Code < 400 {
+		t.Errorf("PUT through link: %d", rec.Code)
+	}
+	if rec := dav(h, "MKCOL", "/link/made", "alice", ""); rec.Code < 400 {
+		t.Errorf("MKCOL through link: %d", rec.Code)
+	}
+	if rec := dav(h, "MOVE", "/a.txt", "alice", "", "Destination", "http://example.com/_gosrvdir/dav/link/a.txt"); rec.Code < 400 {
+		t.Errorf("MOVE through link: %d", rec.Code)
+	}
+	if rec := dav(h, "COPY", "/a.txt", "alice", "", "Destination", "http://example.com/_gosrvdir/dav/link/a.txt"); rec.Code < 400 {
+		t.Errorf("COPY through link: %d", rec.Code)
+	}
+	if rec := dav(h, http.MethodDelete, "/link/out.txt", "alice", ""); rec.Code < 400 {
+		t.Errorf("DELETE through link: %d", rec.Code)
+	}
+	if rec := dav(h, http.MethodGet, "/link/out.txt", "bob", ""); rec.Code < 400 {
+		t.Errorf("GET through link: %d", rec.Code)
+	}
+	expectMissing(t, filepath.Join(outside, "viadav.txt"))
+	expectMissing(t, filepath.Join(outside, "made"))
+	expectMissing(t, filepath.Join(outside, "a.txt"))
+	expectFile(t, filepath.Join(outside, "out.txt"), "outside")
+	expectFile(t, filepath.Join(dir, "a.txt"), "hello")
+
+	// Nothing behind the link is shown, only the link itself
+	if rec := dav(h, "PROPFIND", "/link/out.txt", "bob", "", "Depth", "0"); rec.Code < 400 {
+		t.Errorf("PROPFIND through link: %d", rec.Code)
+	}
+	fsys := &davFS{h: h, creds: h.Creds, user: "bob"}
+	if info, err := fsys.Stat(context.Background(), "/link"); err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("Stat(link) = %v, %v; want the link", info, err)
+	}
+	if _, err := fsys.Stat(context.Background(), "/link/out.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Stat through link: %v", err)
+	}
+
+	// The link itself is an entry of the root and may be removed
+	expectStatus(t, dav(h, http.MethodDelete, "/link", "alice", ""), http.StatusNoContent)
+	expectMissing(t, filepath.Join(dir, "link"))
+	expectFile(t, filepath.Join(outside, "out.txt"), "outside")
+}
+
+func TestDAVSymlinkIntoProtected(t *testing.T) {
+	h, dir, _ := davTree(t)
+	writeTree(t, dir, map[string]string{"priv/deep/d.txt": "deep"})
+	for link, target := range map[string]string{
+		"sub/shortcut": "../priv/deep",
+		"sub/hashes":   "../priv/.htpasswd",
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(link))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A link into priv/ gets priv's credentials
+	for _, user := range []string{"alice", "bob"} {
+		if rec := dav(h, http.MethodGet, "/sub/shortcut/d.txt", user, ""); rec.Code < 400 {
+			t.Errorf("GET through link as %s: %d", user, rec.Code)
+		}
+		if rec := dav(h, "PROPFIND", "/sub/shortcut", user, "", "Depth", "1"); rec.Code < 400 {
+			t.Errorf("PROPFIND through link as %s: %d", user, rec.Code)
+		}
+	}
+	if rec := dav(h, http.MethodPut, "/sub/shortcut/y.txt", "alice", "x"); rec.Code < 400 {
+		t.Errorf("PUT through link: %d", rec.Code)
+	}
+	if rec := dav(h, "MKCOL", "/sub/shortcut/made", "alice", ""); rec.Code < 400 {
+		t.Errorf("MKCOL through link: %d", rec.Code)
+	}
+	if rec := dav(h, "MOVE", "/a.txt", "alice", "", "Destination", "http://example.com/_gosrvdir/dav/sub/shortcut/a.txt"); rec.Code < 400 {
+		t.Errorf("MOVE into link: %d", rec.Code)
+	}
+	if rec := dav(h, "MOVE", "/sub/shortcut/d.txt", "alice", "", "Destination", "http://example.com/_gosrvdir/dav/d.txt"); rec.Code < 400 {
+		t.Errorf("MOVE out of link: %d", rec.Code)
+	}
+	if rec := dav(h, http.MethodDelete, "/sub/shortcut/d.txt", "alice", ""); rec.Code < 400 {
+		t.Errorf("DELETE through link: %d", rec.Code)
+	}
+	// The auth file stays ignored, even for carol and through a link
+	expectStatus(t, dav(h, http.MethodGet, "/sub/hashes", "carol", ""), http.StatusNotFound)
+	expectStatus(t, dav(h, http.MethodGet, "/priv/.htpasswd", "carol", ""), http.StatusNotFound)
+	rec := dav(h, "PROPFIND", "/priv", "carol", "", "Depth", "1")
+	expectStatus(t, rec, http.StatusMultiStatus)
+	if strings.Contains(rec.Body.String(), ".htpasswd") {
+		t.Errorf("PROPFIND shows the auth file:\n%s", rec.Body.String())
+	}
+
+	expectMissing(t, filepath.Join(dir, "priv", "deep", "y.txt"))
+	expectMissing(t, filepath.Join(dir, "priv", "deep", "made"))
+	expectMissing(t, filepath.Join(dir, "priv", "deep", "a.txt"))
+	expectFile(t, filepath.Join(dir, "priv", "deep", "d.txt"), "deep")
+	expectFile(t, filepath.Join(dir, "a.txt"), "hello")
+
+	// carol reaches priv/deep through its own URL
+	expectStatus(t, dav(h, http.MethodGet, "/priv/deep/d.txt", "carol", ""), http.StatusOK)
+}
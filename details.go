@@ -0,0 +1,83 @@
+package gosrvdir
+
+import (
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// FileDetails is the full metadata shown on a file's ?info page.
+type FileDetails struct {
+	Path     string
+	Theme    string
+	Name     string
+	Bytes    int64
+	Size     string
+	Mode     string
+	Owner    string
+	Group    string
+	MIME     string
+	Modified string
+	Hashes   []HashResult
+}
+
+// HashResult is a digest on the details page; Sum is empty until computed.
+type HashResult struct {
+	Algo string
+	Sum  string
+	Err  string
+}
+
+func (h *Handler) serveDetails(w http.ResponseWriter, r *http.Request, filePath, urlPath string, info os.FileInfo, settings *dirSettings) {
+	owner, group := fileOwner(info)
+
+	data := FileDetails{
+		Path:     urlPath,
+		Theme:    settings.Theme,
+		Name:     info.Name(),
+		Bytes:    info.Size(),
+		Size:     formatSize(info.Size()),
+		Mode:     info.Mode().String(),
+		Owner:    owner,
+		Group:    group,
+		MIME:     detectMIME(filePath),
+		Modified: info.ModTime().Format("2006-01-02 15:04:05 MST"),
+	}
+
+	requested := r.URL.Query()["hash"]
+	for _, algo := range hashAlgos {
+		res := HashResult{Algo: algo}
+		if slices.Contains(requested, algo) || slices.Contains(requested, "all") {
+			sum, err := h.hashes.sum(filePath, info, algo)
+			if err != nil {
+				res.Err = "cannot compute"
+			}
+			res.Sum = sum
+		} else if sum, ok := h.hashes.cached(filePath, info, algo); ok {
+			res.Sum = sum
+		}
+		data.Hashes = append(data.Hashes, res)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	DetailsPage(data).Render(w)
+}
+
+// detectMIME prefers the extension and falls back to content sniffing.
+func detectMIME(filePath string) string {
+	if t := mime.TypeByExtension(filepath.Ext(filePath)); t != "" {
+		return t
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, _ := f.Read(buf)
+	return http.DetectContentType(buf[:n])
+}
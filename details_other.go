@@ -0,0 +1,10 @@
+//go:build !unix
+
+package gosrvdir
+
+import "os"
+
+// fileOwner is not available on this platform.
+func fileOwner(info os.FileInfo) (owner, group string) {
+	return "", ""
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package gosrvdir
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// fileOwner resolves the owning user and group names, falling back to
+// numeric IDs when they have no entry in the user database.
+func fileOwner(info os.FileInfo) (owner, group string) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+
+	owner = strconv.FormatUint(uint64(st.Uid), 10)
+	if u, err := user.LookupId(owner); err == nil {
+		owner = u.Username
+	}
+	group = strconv.FormatUint(uint64(st.Gid), 10)
+	if g, err := user.LookupGroupId(group); err == nil {
+		group = g.Name
+	}
+	return owner, group
+}
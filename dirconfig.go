@@ -0,0 +1,253 @@
+package gosrvdir
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DirConfigName is the optional per-directory settings file. Its settings
+// apply to the directory it lives in and are inherited by subdirectories.
+const DirConfigName = ".gosrvdir"
+
+// DirConfig is the content of a .gosrvdir file. Unset fields inherit the
+// parent directory's value; Hide and Ignore patterns accumulate.
+type DirConfig struct {
+	Sort        string   `toml:"sort"` // name, date or size; "-" prefix for descending
+	ShowHidden  *bool    `toml:"show_hidden"`
+	Hide        []string `toml:"hide"`   // not listed, still reachable by URL
+	Ignore      []string `toml:"ignore"` // not listed and not served
+	Readme      *bool    `toml:"readme"`
+	Theme       string   `toml:"theme"`
+	Description string   `toml:"description"` // applies to this directory only
+	AuthFile    string   `toml:"auth_file"`   // htpasswd file, relative to this directory
+}
+
+// dirSettings are the effective settings for one directory.
+type dirSettings struct {
+	Sort        string
+	ShowHidden  bool
+	Hide        []string
+	Ignore      []string
+	Readme      bool
+	Theme       string
+	Description string
+	Creds       Credentials
+	AuthFile    string // name of the directory's own auth_file, if it lives there
+}
+
+// hidden reports whether an entry is left out of listings.
+func (s *dirSettings) hidden(name string) bool {
+	if s.ignored(name) {
+		return true
+	}
+	if !s.ShowHidden && strings.HasPrefix(name, ".") {
+		return true
+	}
+	return matchPattern(s.Hide, name)
+}
+
+// ignored reports whether an entry is treated as if it did not exist.
+func (s *dirSettings) ignored(name string) bool {
+	return name == DirConfigName || name == TrashName || (s.AuthFile != "" && name == s.AuthFile) || matchPattern(s.Ignore, name)
+}
+
+func matchPattern(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *dirSettings) apply(dc *DirConfig, dir string) error {
+	if dc.Sort != "" {
+		s.Sort = dc.Sort
+	}
+	if dc.ShowHidden != nil {
+		s.ShowHidden = *dc.ShowHidden
+	}
+	s.Hide = append(s.Hide[:len(s.Hide):len(s.Hide)], dc.Hide...)
+	s.Ignore = append(s.Ignore[:len(s.Ignore):len(s.Ignore)], dc.Ignore...)
+	if dc.Readme != nil {
+		s.Readme = *dc.Readme
+	}
+	if dc.Theme != "" {
+		s.Theme = dc.Theme
+	}
+	s.Description = dc.Description
+	s.AuthFile = ""
+	if dc.AuthFile != "" {
+		file := dc.AuthFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		// Never serve the password hashes next to the .gosrvdir naming them
+		if filepath.Dir(file) == filepath.Clean(dir) {
+			s.AuthFile = filepath.Base(file)
+		}
+		creds, err := loadCached(&htpasswdCache, file, ParseHtpasswd)
+		if err != nil {
+			return fmt.Errorf("auth_file: %w", err)
+		}
+		s.Creds = creds
+	}
+	return nil
+}
+
+// settingsFor merges the .gosrvdir files from the root down to urlPath.
+// For a file, the result describes its parent directory. found is false
+// when any path component is ignored. A path reached through symlinks
+// gets the settings of where it really is below Dir, so a link cannot
+// sidestep another directory's credentials or ignore rules.
+func (h *Handler) settingsFor(urlPath string) (dirSettings, bool, error) {
+	settings, found, err := h.settingsAlong(urlPath)
+	if err != nil || !found {
+		return settings, found, err
+	}
+	urlPath = path.Clean("/" + urlPath)
+	real, ok := h.rootPath(filepath.Join(h.Dir, filepath.FromSlash(urlPath)))
+	if !ok || real == urlPath {
+		return settings, found, nil
+	}
+	return h.settingsAlong(real)
+}
+
+// rootPath resolves symlinks in the file path p and returns where it
+// really is, as a URL path below Dir. Trailing components that do not
+// exist yet are kept as they are. ok is false when p leads out of Dir or
+// through a dangling symlink.
+func (h *Handler) rootPath(p string) (urlPath string, ok bool) {
+	root, err := filepath.EvalSymlinks(h.Dir)
+	if err != nil {
+		return "", false
+	}
+	var rest []string
+	for {
+		real, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			rel, err := filepath.Rel(root, filepath.Join(append([]string{real}, rest...)...))
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return "", false
+			}
+			return path.Join("/", filepath.ToSlash(rel)), true
+		}
+		if _, err := os.Lstat(p); !errors.Is(err, fs.ErrNotExist) {
+			return "", false
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", false
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+		p = parent
+	}
+}
+
+// settingsAlong is settingsFor by URL path components alone.
+func (h *Handler) settingsAlong(urlPath string) (settings dirSettings, found bool, err error) {
+	settings = dirSettings{
+		ShowHidden: true,
+		Theme:      h.Theme,
+		Creds:      h.Creds,
+	}
+
+	dir := h.Dir
+	found = true
+	parts := strings.Split(strings.Trim(urlPath, "/"), "/")
+	for i := 0; ; i++ {
+		dc, err := loadCached(&dirConfigCache, filepath.Join(dir, DirConfigName), parseDirConfig)
+		if err != nil && !os.IsNotExist(err) && !isNotDir(err) {
+			return settings, found, fmt.Errorf("%s: %w", filepath.Join(dir, DirConfigName), err)
+		}
+		if dc != nil {
+			if err := settings.apply(dc, dir); err != nil {
+				return settings, found, fmt.Errorf("%s: %w", filepath.Join(dir, DirConfigName), err)
+			}
+		} else {
+			settings.Description = ""
+			settings.AuthFile = ""
+		}
+
+		if i >= len(parts) || parts[i] == "" {
+			return settings, found, nil
+		}
+		if settings.ignored(parts[i]) {
+			found = false
+		}
+		dir = filepath.Join(dir, parts[i])
+	}
+}
+
+func isNotDir(err error) bool {
+	return errors.Is(err, syscall.ENOTDIR)
+}
+
+func parseDirConfig(file string) (*DirConfig, error) {
+	var dc DirConfig
+	md, err := toml.DecodeFile(file, &dc)
+	if err != nil {
+		return nil, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown key %s", undecoded[0])
+	}
+	return &dc, nil
+}
+
+// fileCache memoizes parsed files until their size or mtime changes.
+type fileCache[T any] struct {
+	mu      sync.Mutex
+	entries map[string]fileCacheEntry[T]
+}
+
+type fileCacheEntry[T any] struct {
+	modTime time.Time
+	size    int64
+	value   T
+}
+
+var (
+	dirConfigCache fileCache[*DirConfig]
+	htpasswdCache  fileCache[Credentials]
+)
+
+func loadCached[T any](c *fileCache[T], file string, parse func(string) (T, error)) (T, error) {
+	var zero T
+	info, err := os.Stat(file)
+	if err != nil {
+		return zero, err
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[file]
+	c.mu.Unlock()
+	if ok && e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+		return e.value, nil
+	}
+
+	value, err := parse(file)
+	if err != nil {
+		log.Printf("%s: %v", file, err)
+		return zero, err
+	}
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]fileCacheEntry[T])
+	}
+	c.entries[file] = fileCacheEntry[T]{info.ModTime(), info.Size(), value}
+	c.mu.Unlock()
+	return value, nil
+}
@@ -0,0 +1,140 @@
+package gosrvdir
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTree creates files below dir from slash-separated names.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// get requests urlPath from h as user ("" for none, password "pw").
+func get(h *Handler, urlPath, user string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, urlPath, nil)
+	if user != "" {
+		r.SetBasicAuth(user, "pw")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestAuthFileIgnored(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"priv/" + DirConfigName:     "auth_file = \".htpasswd\"\n",
+		"priv/.htpasswd":            "carol:" + hashPassword(t, "pw") + "\n",
+		"priv/p.txt":                "private",
+		"priv/sub/.htpasswd":        "not an auth file here",
+		"priv/sub/" + DirConfigName: "readme = false\n",
+	})
+	h := &Handler{Dir: dir}
+
+	expectStatus(t, get(h, "/priv/.htpasswd", "carol"), http.StatusNotFound)
+	expectStatus(t, get(h, "/priv/p.txt", "carol"), http.StatusOK)
+	// Only the directory's own auth_file is left out
+	expectStatus(t, get(h, "/priv/sub/.htpasswd", "carol"), http.StatusOK)
+
+	rec := get(h, "/priv/?format=plain", "carol")
+	expectStatus(t, rec, http.StatusOK)
+	if strings.Contains(rec.Body.String(), ".htpasswd") {
+		t.Errorf("listing shows the auth file:\n%s", rec.Body.String())
+	}
+}
+
+func TestSettingsFollowSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		DirConfigName:           "ignore = [\"*.tmp\"]\n",
+		"skip.tmp":              "ignored",
+		"priv/" + DirConfigName: "auth_file = \".htpasswd\"\n",
+		"priv/.htpasswd":        "carol:" + hashPassword(t, "pw") + "\n",
+		"priv/deep/x.txt":       "carol only",
+		"sub/s.txt":             "public",
+	})
+	links := map[string]string{
+		"sub/shortcut":   "../priv/deep",
+		"sub/x.txt":      "../priv/deep/x.txt",
+		"sub/skip.txt":   "../skip.tmp",
+		"sub/hashes.txt": "../priv/.htpasswd",
+		"sub/same":       ".",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(link))); err != nil {
+			t.Skip("symlinks unavailable:", err)
+		}
+	}
+	h := &Handler{Dir: dir, Creds: Credentials{"bob": hashPassword(t, "pw")}}
+
+	tests := []struct {
+		path string
+		user string
+		code int
+	}{
+		// A link into priv/ needs priv's credentials
+		{"/sub/shortcut/x.txt", "bob", http.StatusUnauthorized},
+		{"/sub/shortcut/", "bob", http.StatusUnauthorized},
+		{"/sub/x.txt", "bob", http.StatusUnauthorized},
+		{"/sub/shortcut/x.txt", "carol", http.StatusOK},
+		// Links to ignored files do not make them reachable
+		{"/sub/skip.txt", "bob", http.StatusNotFound},
+		{"/sub/hashes.txt", "carol", http.StatusNotFound},
+		// Links within the same rules work as before
+		{"/sub/same/s.txt", "bob", http.StatusOK},
+		{"/sub/s.txt", "bob", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+" as "+tt.user, func(t *testing.T) {
+			expectStatus(t, get(h, tt.path, tt.user), tt.code)
+		})
+	}
+}
+
+func TestRootPath(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	writeTree(t, dir, map[string]string{"a/b/c.txt": "c"})
+	for link, target := range map[string]string{"l": "a/b", "out": outside, "dangling": "missing"} {
+		if err := os.Symlink(target, filepath.Join(dir, link)); err != nil {
+			t.Skip("symlinks unavailable:", err)
+		}
+	}
+	h := &Handler{Dir: dir}
+
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"", "/", true},
+		{"a/b/c.txt", "/a/b/c.txt", true},
+		{"l/c.txt", "/a/b/c.txt", true},
+		{"l/new/file", "/a/b/new/file", true},
+		{"missing/file", "/missing/file", true},
+		{"out", "", false},
+		{"out/new", "", false},
+		{"dangling", "", false},
+		{"dangling/x", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := h.rootPath(filepath.Join(dir, filepath.FromSlash(tt.path)))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("rootPath(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package gosrvdir
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	dirSizeTTL        = 5 * time.Minute // recompute subtree sizes after this
+	dirSizeMaxWalks   = 2               // concurrent background walks
+	dirSizeMaxEntries = 1_000_000       // stop counting huge subtrees
+	dirSizeMaxCached  = 10_000          // directories whose sizes are kept
+)
+
+var errTooManyEntries = errors.New("too many entries")
+
+// DirSize is the total size of a directory subtree.
+type DirSize struct {
+	Bytes   int64
+	Files   int64
+	Partial bool // the walk hit dirSizeMaxEntries or unreadable directories
+}
+
+// dirSizer computes subtree sizes in the background and caches them.
+// The zero value is ready to use.
+type dirSizer struct {
+	mu      sync.Mutex
+	entries map[string]dirSizeEntry
+	pending map[string]bool
+	sem     chan struct{}
+}
+
+type dirSizeEntry struct {
+	size     DirSize
+	modTime  time.Time
+	computed time.Time
+}
+
+// lookup returns the cached size of dir, scheduling walk in the
+// background when there is none or it is stale. A stale size is still
+// returned.
+func (d *dirSizer) lookup(dir string, info os.FileInfo, walk func() DirSize) (DirSize, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.entries == nil {
+		d.entries = make(map[string]dirSizeEntry)
+		d.pending = make(map[string]bool)
+		d.sem = make(chan struct{}, dirSizeMaxWalks)
+	}
+
+	e, ok := d.entries[dir]
+	fresh := ok && e.modTime.Equal(info.ModTime()) && time.Since(e.computed) < dirSizeTTL
+	if !fresh && !d.pending[dir] {
+		d.pending[dir] = true
+		go d.compute(dir, info.ModTime(), walk)
+	}
+	return e.size, ok
+}
+
+func (d *dirSizer) compute(dir string, modTime time.Time, walk func() DirSize) {
+	d.sem <- struct{}{}
+	size := walk()
+	<-d.sem
+
+	d.mu.Lock()
+	if _, ok := d.entries[dir]; !ok && len(d.entries) >= dirSizeMaxCached {
+		d.evict()
+	}
+	d.entries[dir] = dirSizeEntry{size: size, modTime: modTime, computed: time.Now()}
+	delete(d.pending, dir)
+	d.mu.Unlock()
+}
+
+// evict drops expired sizes or, when none are, the oldest one.
+// d.mu must be held.
+func (d *dirSizer) evict() {
+	var oldest string
+	for dir, e := range d.entries {
+		if time.Since(e.computed) >= dirSizeTTL {
+			delete(d.entries, dir)
+		} else if oldest == "" || e.computed.Before(d.entries[oldest].computed) {
+			oldest = dir
+		}
+	}
+	if len(d.entries) >= dirSizeMaxCached {
+		delete(d.entries, oldest)
+	}
+}
+
+// walkSize adds up the regular files below dir that its listing would
+// show: ignored and hidden entries and subtrees with credentials other
+// than settings' are skipped, and symlinks are not followed.
+func (h *Handler) walkSize(dir, urlDir string, settings *dirSettings) DirSize {
+	var size DirSize
+	var seen int
+
+	var walk func(dir, urlDir string, s *dirSettings) error
+	walk = func(dir, urlDir string, s *dirSettings) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			size.Partial = true
+			return nil
+		}
+		for _, entry := range entries {
+			if seen++; seen > dirSizeMaxEntries {
+				return errTooManyEntries
+			}
+
+			name := entry.Name()
+			if s.hidden(name) {
+				continue
+			}
+			if entry.IsDir() {
+				entryURL := path.Join(urlDir, name)
+				sub, found, err := h.settingsFor(entryURL)
+				if err != nil || !found || !sameCreds(sub.Creds, settings.Creds) {
+					continue
+				}
+				if err := walk(filepath.Join(dir, name), entryURL, &sub); err != nil {
+					return err
+				}
+				continue
+			}
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			size.Bytes += info.Size()
+			size.Files++
+		}
+		return nil
+	}
+
+	if err := walk(dir, urlDir, settings); err != nil {
+		size.Partial = true
+	}
+	return size
+}
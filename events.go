@@ -0,0 +1,124 @@
+package gosrvdir
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxWatchers     = 64               // concurrent ?events subscribers
+	eventsKeepAlive = 30 * time.Second // comment line to keep proxies from timing out
+)
+
+// listingEvent is the data of a create, modify or delete event.
+type listingEvent struct {
+	Name   string `json:"name"`             // display name, "/" suffixed for directories
+	HTML   string `json:"html,omitempty"`   // rendered FileTable row
+	Before string `json:"before,omitempty"` // existing row to insert a new one in front of
+}
+
+// serveEvents streams changes to a directory's visible entries as
+// Server-Sent Events for the listing to apply in place.
+func (h *Handler) serveEvents(w http.ResponseWriter, r *http.Request, dirPath, urlPath string, settings *dirSettings) {
+	if h.watchers.Add(1) > maxWatchers {
+		h.watchers.Add(-1)
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Too many watchers", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.watchers.Add(-1)
+
+	entries, err := h.readEntries(dirPath, urlPath, settings)
+	if err != nil {
+		http.Error(w, "Cannot read directory", http.StatusInternalServerError)
+		return
+	}
+	known := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		known[e.Name] = true
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	if rc.Flush() != nil {
+		return
+	}
+
+	changes := watchDir(r.Context(), dirPath)
+	ping := time.NewTicker(eventsKeepAlive)
+	defer ping.Stop()
+
+	for {
+		select {
+		case names, ok := <-changes:
+			if !ok {
+				return
+			}
+			entries, err := h.readEntries(dirPath, urlPath, settings)
+			if err != nil {
+				return
+			}
+			writeListingEvents(w, names, entries, known)
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// writeListingEvents compares the changed names against the fresh listing
+// and the rows the client already has, updating known as it goes.
+func writeListingEvents(w http.ResponseWriter, names []string, entries []FileInfo, known map[string]bool) {
+	index := make(map[string]int, len(entries))
+	for i, e := range entries {
+		index[e.Name] = i
+	}
+
+	for _, name := range names {
+		// A name may now be a file where a directory was, or vice versa
+		for _, display := range []string{name, name + "/"} {
+			i, exists := index[display]
+			switch {
+			case exists:
+				ev := listingEvent{Name: display, HTML: renderRow(entries[i])}
+				kind := "modify"
+				if !known[display] {
+					kind = "create"
+					for _, next := range entries[i+1:] {
+						if known[next.Name] {
+							ev.Before = next.Name
+							break
+						}
+					}
+				}
+				known[display] = true
+				writeEvent(w, kind, ev)
+			case known[display]:
+				delete(known, display)
+				writeEvent(w, "delete", listingEvent{Name: display})
+			}
+		}
+	}
+}
+
+func renderRow(entry FileInfo) string {
+	var b strings.Builder
+	FileRow(entry).Render(&b)
+	return b.String()
+}
+
+func writeEvent(w http.ResponseWriter, kind string, ev listingEvent) {
+	data, _ := json.Marshal(ev)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", kind, data)
+}
@@ -0,0 +1,59 @@
+package gosrvdir
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// listingEntry is a directory entry in JSON listings.
+type listingEntry struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Dir      bool      `json:"dir"`
+	Size     *int64    `json:"size,omitempty"`  // omitted for directories not yet measured
+	Files    *int64    `json:"files,omitempty"` // directories only
+	Modified time.Time `json:"modified"`
+}
+
+// serveListingJSON answers ?format=json for a directory.
+func serveListingJSON(w http.ResponseWriter, urlPath string, entries []FileInfo) {
+	out := struct {
+		Path    string         `json:"path"`
+		Entries []listingEntry `json:"entries"`
+	}{Path: urlPath, Entries: []listingEntry{}}
+
+	for _, e := range entries {
+		le := listingEntry{
+			Name:     strings.TrimSuffix(e.Name, "/"),
+			Path:     e.Path,
+			Dir:      e.IsDir,
+			Modified: e.Modified,
+		}
+		if e.Size != "" {
+			le.Size = &e.SizeBytes
+		}
+		if e.IsDir && e.Size != "" {
+			le.Files = &e.Files
+		}
+		out.Entries = append(out.Entries, le)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// serveListingPlain answers ?format=plain with one tab-separated
+// "name  size  modified" line per entry; unknown sizes are "-".
+func serveListingPlain(w http.ResponseWriter, entries []FileInfo) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, e := range entries {
+		size := "-"
+		if e.Size != "" {
+			size = fmt.Sprint(e.SizeBytes)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Name, size, e.Modified.UTC().Format(time.RFC3339))
+	}
+}
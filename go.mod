@@ -1,14 +1,23 @@
 module github.com/axelrhd/gosrvdir
 
-go 1.25.5
+go 1.26.0
 
 require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/alecthomas/chroma/v2 v2.27.0
+	github.com/andybalholm/brotli v1.2.6
+	github.com/fsnotify/fsnotify v1.10.1
+	github.com/klauspost/compress v1.20.1
 	github.com/urfave/cli/v3 v3.6.2
+	github.com/yuin/goldmark v1.8.6
+	golang.org/x/crypto v0.47.0
+	golang.org/x/image v0.46.0
+	golang.org/x/net v0.48.0
+	golang.org/x/term v0.39.0
 	maragu.dev/gomponents v1.2.0
 )
 
 require (
-	golang.org/x/crypto v0.47.0 // indirect
-	golang.org/x/sys v0.40.0 // indirect
-	golang.org/x/term v0.39.0 // indirect
+	github.com/dlclark/regexp2/v2 v2.2.1 // indirect
+	golang.org/x/sys v0.48.0 // indirect
 )
@@ -6,14 +6,65 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// reservedPrefix is the URL namespace for gosrvdir's own endpoints.
+const reservedPrefix = "/_gosrvdir/"
+
 type Handler struct {
-	Dir   string
-	Theme string
-	Creds Credentials
+	Dir     string
+	Theme   string
+	Creds   Credentials
+	Filter  *IPFilter
+	Metrics *Metrics
+	Version string
+	Started time.Time
+
+	// ServeMetrics exposes Metrics under /_gosrvdir/metrics.
+	ServeMetrics bool
+	// RenderMarkdown links .md files to their rendered view.
+	RenderMarkdown bool
+	// DirSizes shows recursive directory sizes once computed.
+	DirSizes bool
+	// Index serves index.html or index.htm instead of a listing.
+	Index bool
+	// CleanURLs serves /about from about.html when /about does not exist.
+	CleanURLs bool
+	// SPAFallback is the URL path of the page served for missing
+	// extensionless paths, e.g. "/index.html" for single-page apps.
+	SPAFallback string
+	// LiveReload reloads open HTML pages when files below Dir change.
+	LiveReload bool
+	// FileCache and ListingCache are Cache-Control values for files and
+	// directory listings; empty sends none.
+	FileCache    string
+	ListingCache string
+	// WriteUsers may create, rename, move and delete entries from the
+	// listing. Nobody may when empty or when auth was skipped.
+	WriteUsers []string
+	// TrashRetention keeps deleted entries in TrashName for this long;
+	// zero deletes them immediately.
+	TrashRetention time.Duration
+	// WebDAV serves Dir under /_gosrvdir/dav/, writable for WriteUsers.
+	WebDAV bool
+	// Compress negotiates gzip, brotli or zstd for text responses and
+	// serves precompressed .gz/.br/.zst siblings of files.
+	Compress bool
+
+	thumbs thumbCache
+	hashes hashCache
+	sizes  dirSizer
+
+	reload liveReload
+	csrf   csrfKey
+	locks  davLocks
+
+	watchers atomic.Int64 // open ?events and live-reload streams
 }
 
 type FileInfo struct {
@@ -22,30 +73,115 @@ type FileInfo struct {
 	Size    string
 	ModTime string
 	IsDir   bool
+	View    string // page opened from the listing, e.g. "source"
+
+	SizeBytes int64
+	Files     int64 // files below a directory, with DirSizes
+	Modified  time.Time
 }
 
 type ListingData struct {
-	Path    string
-	Theme   string
-	Entries []FileInfo
+	Path        string
+	Theme       string
+	Description string
+	Readme      string
+	ReadmeHTML  string
+	View        string
+	HasMedia    bool
+	Entries     []FileInfo
+	Pages       Pagination
+	CSRF        string // form token; empty when the user may not write
+	Trash       bool   // deletes go to the trash
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if h.Creds != nil {
-		user, pass, ok := r.BasicAuth()
-		if !ok || !CheckPassword(h.Creds, user, pass) {
-			w.Header().Set("WWW-Authenticate", `Basic realm="gosrvdir"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if h.Metrics == nil {
+		h.serveCompressed(w, r)
+		return
+	}
+
+	rec := &statusRecorder{ResponseWriter: w}
+	h.serveCompressed(rec, r)
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	h.Metrics.observeRequest(r.Method, rec.code, rec.bytes)
+}
+
+func (h *Handler) serveCompressed(w http.ResponseWriter, r *http.Request) {
+	if !h.Compress {
+		h.serve(w, r)
+		return
+	}
+
+	cw := &compressWriter{ResponseWriter: w, r: r}
+	defer cw.Close()
+	h.serve(cw, r)
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	bypassAuth := false
+	if h.Filter != nil {
+		var allowed bool
+		allowed, bypassAuth = h.Filter.Check(r.RemoteAddr)
+		if !allowed {
+			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 	}
 
+	// Health checks bypass auth so supervisors need no credentials
+	switch r.URL.Path {
+	case reservedPrefix + "healthz":
+		h.serveHealth(w, r, false)
+		return
+	case reservedPrefix + "readyz":
+		h.serveHealth(w, r, true)
+		return
+	}
+
+	if h.WebDAV && strings.HasPrefix(r.URL.Path+"/", davPrefix) {
+		h.serveDAV(w, r, bypassAuth)
+		return
+	}
+
 	// Clean and resolve path
 	urlPath := path.Clean(r.URL.Path)
 	if urlPath == "" {
 		urlPath = "/"
 	}
 
+	settings, found, err := h.settingsFor(urlPath)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	r, ok := h.authenticate(w, r, settings.Creds, bypassAuth)
+	if !ok {
+		return
+	}
+
+	if h.ServeMetrics && r.URL.Path == reservedPrefix+"metrics" {
+		h.Metrics.ServeHTTP(w, r)
+		return
+	}
+
+	if h.LiveReload && r.URL.Path == reservedPrefix+"livereload" {
+		h.serveLiveReload(w, r)
+		return
+	}
+
+	if r.URL.Path == reservedPrefix+"trash" {
+		h.serveTrash(w, r, &settings)
+		return
+	}
+
+	if !found {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
 	filePath := filepath.Join(h.Dir, filepath.FromSlash(urlPath))
 
 	// Security: ensure we don't escape the root directory
@@ -56,33 +192,154 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	info, err := os.Stat(filePath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			http.Error(w, "Not Found", http.StatusNotFound)
-		} else {
+		if !os.IsNotExist(err) {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		} else if isManifestRequest(filePath) {
+			h.serveManifest(w, r, filepath.Dir(filePath), manifestDir(urlPath), &settings, false)
+		} else if page, ok := h.resolveMissing(r, urlPath, &settings); ok {
+			h.serveFile(w, r, page)
+		} else {
+			http.Error(w, "Not Found", http.StatusNotFound)
 		}
 		return
 	}
 
 	if info.IsDir() {
-		h.serveDirectory(w, r, filePath, urlPath)
-	} else {
+		h.serveDirectory(w, r, filePath, urlPath, info, &settings)
+		return
+	}
+
+	if r.URL.Query().Has("info") {
+		h.serveDetails(w, r, filePath, urlPath, info, &settings)
+		return
+	}
+
+	switch r.URL.Query().Get("view") {
+	case "thumb":
+		h.serveThumbnail(w, r, filePath, info)
+	case "source":
+		h.serveSource(w, r, filePath, urlPath, info, &settings)
+	case "render":
+		h.serveMarkdown(w, r, filePath, urlPath, info, &settings)
+	case "table":
+		h.serveTable(w, r, filePath, urlPath, info, &settings)
+	case "tree":
+		h.serveJSONTree(w, r, filePath, urlPath, info, &settings)
+	case "play":
+		h.servePlayer(w, r, urlPath, &settings)
+	default:
 		h.serveFile(w, r, filePath)
 	}
 }
 
-func (h *Handler) serveDirectory(w http.ResponseWriter, r *http.Request, filePath, urlPath string) {
+// authenticate checks Basic auth against creds unless there are none or
+// the client may bypass them, answering 401 on failure. The returned
+// request carries the authenticated user.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request, creds Credentials, bypassAuth bool) (*http.Request, bool) {
+	if creds == nil || bypassAuth {
+		return r, true
+	}
+
+	user, pass, ok := r.BasicAuth()
+	if !ok || !CheckPassword(creds, user, pass) {
+		if ok && h.Metrics != nil {
+			h.Metrics.authFailures.Add(1)
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="gosrvdir"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return r, false
+	}
+	return withUser(r, user), true
+}
+
+func (h *Handler) serveDirectory(w http.ResponseWriter, r *http.Request, filePath, urlPath string, info os.FileInfo, settings *dirSettings) {
 	// Ensure trailing slash for directories
 	if !strings.HasSuffix(r.URL.Path, "/") {
 		http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
 		return
 	}
 
-	entries, err := os.ReadDir(filePath)
+	if r.Method == http.MethodPost {
+		switch q := r.URL.Query(); {
+		case q.Has("zip"):
+			h.serveZip(w, r, filePath, urlPath, settings)
+			return
+		case q.Has("mkdir"), q.Has("rename"), q.Has("move"), q.Has("delete"):
+			h.serveWrite(w, r, filePath, urlPath, settings)
+			return
+		}
+	}
+
+	if h.Index && !listingQuery(r.URL.Query()) {
+		if index, ok := findIndex(filePath, settings); ok {
+			h.serveFile(w, r, index)
+			return
+		}
+	}
+
+	if r.URL.Query().Has("events") {
+		h.serveEvents(w, r, filePath, urlPath, settings)
+		return
+	}
+
+	if h.Metrics != nil {
+		defer func(start time.Time) {
+			h.Metrics.observeListing(time.Since(start))
+		}(time.Now())
+	}
+
+	query := r.URL.Query()
+	if query.Get("view") == "usage" {
+		h.serveUsage(w, r, filePath, urlPath, settings)
+		return
+	}
+	if query.Get("manifest") == "json" {
+		h.serveManifest(w, r, filePath, urlPath, settings, true)
+		return
+	}
+
+	// Everything below is rendered from entries and settings alone
+	if h.ListingCache != "" {
+		w.Header().Set("Cache-Control", h.ListingCache)
+	}
+
+	format := query.Get("format")
+	if query.Get("view") == "playlist" || format == "m3u8" || format == "json" || format == "plain" {
+		entries, err := h.readEntries(filePath, urlPath, settings)
+		if err != nil {
+			http.Error(w, "Cannot read directory", http.StatusInternalServerError)
+			return
+		}
+		if checkNotModified(w, r, h.listingETag(r, info, entries, settings), listingModTime(info, entries)) {
+			return
+		}
+
+		switch {
+		case query.Get("view") == "playlist":
+			h.servePlaylist(w, r, urlPath, entries, settings)
+		case format == "m3u8":
+			h.serveM3U(w, r, urlPath, entries)
+		case format == "json":
+			serveListingJSON(w, urlPath, entries)
+		default:
+			serveListingPlain(w, entries)
+		}
+		return
+	}
+
+	pages := parsePagination(query)
+	entries, hasMedia, err := h.readPage(filePath, urlPath, settings, &pages)
 	if err != nil {
 		http.Error(w, "Cannot read directory", http.StatusInternalServerError)
 		return
 	}
+	if pages.Page > pages.Count() {
+		http.Error(w, "Page out of range", http.StatusNotFound)
+		return
+	}
+	if checkNotModified(w, r, h.listingETag(r, info, entries, settings), listingModTime(info, entries)) {
+		return
+	}
 
 	var files []FileInfo
 
@@ -94,7 +351,61 @@ func (h *Handler) serveDirectory(w http.ResponseWriter, r *http.Request, filePat
 			IsDir: true,
 		})
 	}
+	files = append(files, entries...)
+
+	data := ListingData{
+		Path:        urlPath,
+		Theme:       settings.Theme,
+		Description: settings.Description,
+		Entries:     files,
+		HasMedia:    hasMedia,
+		Pages:       pages,
+	}
+	if user, ok := h.writeUser(r); ok {
+		data.CSRF = h.csrf.token(user)
+		data.Trash = h.TrashRetention > 0
+	}
+	if query.Get("view") == "grid" {
+		data.View = "grid"
+	}
+	if settings.Readme {
+		name, text := readReadme(filePath, settings)
+		if isMarkdown(name) {
+			data.ReadmeHTML, _, _ = renderMarkdown([]byte(text), urlPath)
+		}
+		if data.ReadmeHTML == "" {
+			data.Readme = text
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	RenderListing(w, data)
+}
+
+// readEntries lists a directory's visible entries in display order.
+func (h *Handler) readEntries(filePath, urlPath string, settings *dirSettings) ([]FileInfo, error) {
+	entries, err := visibleEntries(filePath, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	files := h.entryInfos(filePath, urlPath, entries, settings)
+	sortEntries(files, settings.Sort)
+	return files, nil
+}
 
+// visibleEntries reads a directory's names without stat'ing each entry.
+func visibleEntries(filePath string, settings *dirSettings) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(entries, func(e os.DirEntry) bool { return settings.hidden(e.Name()) }), nil
+}
+
+// entryInfos stats entries for display, dropping any that vanished.
+func (h *Handler) entryInfos(filePath, urlPath string, entries []os.DirEntry, settings *dirSettings) []FileInfo {
+	files := make([]FileInfo, 0, len(entries))
 	for _, entry := range entries {
 		info, err := entry.Info()
 		if err != nil {
@@ -105,24 +416,58 @@ func (h *Handler) serveDirectory(w http.ResponseWriter, r *http.Request, filePat
 		entryPath := path.Join(urlPath, name)
 
 		fi := FileInfo{
-			Name:    name,
-			Path:    entryPath,
-			ModTime: info.ModTime().Format("2006-01-02 15:04"),
-			IsDir:   entry.IsDir(),
+			Name:     name,
+			Path:     entryPath,
+			ModTime:  info.ModTime().Format("2006-01-02 15:04"),
+			IsDir:    entry.IsDir(),
+			Modified: info.ModTime(),
 		}
 
 		if entry.IsDir() {
 			fi.Name += "/"
 			fi.Path += "/"
+			if h.DirSizes {
+				h.dirSize(&fi, filepath.Join(filePath, name), info, settings)
+			}
 		} else {
 			fi.Size = formatSize(info.Size())
+			fi.SizeBytes = info.Size()
+			fi.View = h.defaultView(name)
 		}
 
 		files = append(files, fi)
 	}
+	return files
+}
+
+// dirSize fills in the recursive size of a listed directory once known.
+// Subtrees with other credentials get none, as it would reveal their
+// contents.
+func (h *Handler) dirSize(fi *FileInfo, dir string, info os.FileInfo, settings *dirSettings) {
+	urlDir := strings.TrimSuffix(fi.Path, "/")
+	sub, _, err := h.settingsFor(urlDir)
+	if err != nil || !sameCreds(sub.Creds, settings.Creds) {
+		return
+	}
+	size, ok := h.sizes.lookup(dir, info, func() DirSize { return h.walkSize(dir, urlDir, &sub) })
+	if !ok {
+		return
+	}
+	fi.Size = formatSize(size.Bytes)
+	if size.Partial {
+		fi.Size = "≥ " + fi.Size
+	}
+	fi.SizeBytes = size.Bytes
+	fi.Files = size.Files
+}
+
+// sortEntries orders a listing by name, date or size ("-" prefix reverses),
+// always keeping ".." first and directories before files.
+func sortEntries(files []FileInfo, order string) {
+	desc := strings.HasPrefix(order, "-")
+	key := strings.TrimPrefix(order, "-")
 
-	// Sort: directories first, then by name
-	sort.Slice(files, func(i, j int) bool {
+	sort.SliceStable(files, func(i, j int) bool {
 		// Keep ".." at the top
 		if files[i].Name == ".." {
 			return true
@@ -134,21 +479,86 @@ func (h *Handler) serveDirectory(w http.ResponseWriter, r *http.Request, filePat
 		if files[i].IsDir != files[j].IsDir {
 			return files[i].IsDir
 		}
+
+		a, b := files[i], files[j]
+		if desc {
+			a, b = b, a
+		}
+		switch key {
+		case "date":
+			if !a.Modified.Equal(b.Modified) {
+				return a.Modified.Before(b.Modified)
+			}
+		case "size":
+			if a.SizeBytes != b.SizeBytes {
+				return a.SizeBytes < b.SizeBytes
+			}
+		}
 		// Alphabetical (case-insensitive)
-		return strings.ToLower(files[i].Name) < strings.ToLower(files[j].Name)
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
 	})
+}
 
-	data := ListingData{
-		Path:    urlPath,
-		Theme:   h.Theme,
-		Entries: files,
+// readmeNames are checked in order when a directory enables readme.
+var readmeNames = []string{"README.md", "README.txt", "README", "readme.md", "readme.txt"}
+
+const maxReadmeSize = 256 << 10
+
+func readReadme(dir string, settings *dirSettings) (name, text string) {
+	for _, name := range readmeNames {
+		if settings.ignored(name) {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || info.IsDir() || info.Size() > maxReadmeSize {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		return name, string(data)
 	}
+	return "", ""
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	RenderListing(w, data)
+// defaultView picks the page a listing links a file to.
+func (h *Handler) defaultView(name string) string {
+	switch {
+	case isSourceFile(name):
+		return "source"
+	case h.RenderMarkdown && isMarkdown(name):
+		return "render"
+	case isDelimited(name):
+		return "table"
+	case isJSON(name):
+		return "tree"
+	case isMedia(name):
+		return "play"
+	}
+	return ""
 }
 
 func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, filePath string) {
+	if h.Metrics != nil {
+		h.Metrics.activeDownloads.Add(1)
+		defer h.Metrics.activeDownloads.Add(-1)
+	}
+
+	if h.FileCache != "" {
+		w.Header().Set("Cache-Control", h.FileCache)
+	}
+
+	if info, err := os.Stat(filePath); err == nil && info.Mode().IsRegular() {
+		if h.LiveReload && isHTML(filePath) {
+			h.serveHTMLWithReload(w, r, filePath, info)
+			return
+		}
+		if h.Compress && servePrecompressed(w, r, filePath, info) {
+			return
+		}
+	}
+
 	// Don't set Content-Disposition — let browser decide (inline preview)
 	http.ServeFile(w, r, filePath)
 }
@@ -0,0 +1,111 @@
+package gosrvdir
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+)
+
+// hashAlgos are the digests offered for downloads, strongest first.
+var hashAlgos = []string{"sha256", "sha1", "md5"}
+
+const maxHashCacheEntries = 10000
+
+func newHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("unknown hash %q", algo)
+}
+
+// hashCache memoizes file digests by path, mtime and size, and makes
+// concurrent requests for the same digest share one computation.
+// The zero value is ready to use.
+type hashCache struct {
+	mu       sync.Mutex
+	sums     map[string]string
+	inflight map[string]*hashCall
+}
+
+type hashCall struct {
+	done chan struct{}
+	sum  string
+	err  error
+}
+
+func hashKey(filePath string, info os.FileInfo, algo string) string {
+	return fmt.Sprintf("%s|%d|%d|%s", filePath, info.ModTime().UnixNano(), info.Size(), algo)
+}
+
+// cached returns a digest only if it has already been computed.
+func (c *hashCache) cached(filePath string, info os.FileInfo, algo string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sum, ok := c.sums[hashKey(filePath, info, algo)]
+	return sum, ok
+}
+
+// sum returns the hex digest of a file, streaming it from disk on a miss.
+func (c *hashCache) sum(filePath string, info os.FileInfo, algo string) (string, error) {
+	key := hashKey(filePath, info, algo)
+
+	c.mu.Lock()
+	if sum, ok := c.sums[key]; ok {
+		c.mu.Unlock()
+		return sum, nil
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.sum, call.err
+	}
+	if c.inflight == nil {
+		c.inflight = make(map[string]*hashCall)
+		c.sums = make(map[string]string)
+	}
+	call := &hashCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.sum, call.err = hashFile(filePath, algo)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		if len(c.sums) >= maxHashCacheEntries {
+			clear(c.sums)
+		}
+		c.sums[key] = call.sum
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.sum, call.err
+}
+
+func hashFile(filePath, algo string) (string, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
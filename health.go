@@ -0,0 +1,68 @@
+package gosrvdir
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+type healthStatus struct {
+	Status       string `json:"status"`
+	Version      string `json:"version"`
+	Uptime       string `json:"uptime"`
+	UptimeSecs   int64  `json:"uptime_seconds"`
+	RootReadable bool   `json:"root_readable"`
+	Error        string `json:"error,omitempty"`
+}
+
+// serveHealth answers /_gosrvdir/healthz and /_gosrvdir/readyz.
+// Liveness always succeeds while the process runs; readiness fails with
+// 503 once the served root can no longer be read.
+func (h *Handler) serveHealth(w http.ResponseWriter, r *http.Request, ready bool) {
+	status := healthStatus{
+		Status:       "ok",
+		Version:      h.Version,
+		RootReadable: true,
+	}
+	if !h.Started.IsZero() {
+		uptime := time.Since(h.Started)
+		status.Uptime = uptime.Round(time.Second).String()
+		status.UptimeSecs = int64(uptime.Seconds())
+	}
+
+	code := http.StatusOK
+	if err := checkReadable(h.Dir); err != nil {
+		status.RootReadable = false
+		// The endpoint is public; keep paths and OS details in the log
+		log.Printf("health: %v", err)
+		status.Error = "root not readable"
+		if ready {
+			status.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if r.Method != http.MethodHead {
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+func checkReadable(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Readdirnames(1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
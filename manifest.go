@@ -0,0 +1,197 @@
+package gosrvdir
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ManifestName is the virtual checksum file offered in every directory.
+const ManifestName = "SHA256SUMS"
+
+// ManifestEntry is one file in a directory manifest.
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type manifestFile struct {
+	rel  string
+	abs  string
+	info os.FileInfo
+}
+
+// serveManifest answers SHA256SUMS (sha256sum -c format) or, with
+// ?manifest=json, a JSON manifest for a directory. ?recursive includes
+// subdirectories that share the directory's credentials.
+func (h *Handler) serveManifest(w http.ResponseWriter, r *http.Request, dirPath, dirURL string, settings *dirSettings, asJSON bool) {
+	recursive := r.URL.Query().Has("recursive")
+
+	files, err := h.collectManifestFiles(dirPath, dirURL, settings, recursive)
+	if err != nil {
+		http.Error(w, "Cannot read directory", http.StatusInternalServerError)
+		return
+	}
+
+	entries, err := h.hashManifest(r.Context(), files)
+	if err != nil {
+		if r.Context().Err() == nil {
+			http.Error(w, "Cannot compute checksums", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s  %s\n", e.SHA256, e.Path)
+	}
+}
+
+func (h *Handler) collectManifestFiles(dirPath, dirURL string, settings *dirSettings, recursive bool) ([]manifestFile, error) {
+	var files []manifestFile
+
+	var walk func(dir, urlDir, rel string, s *dirSettings) error
+	walk = func(dir, urlDir, rel string, s *dirSettings) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if s.hidden(name) {
+				continue
+			}
+			abs := filepath.Join(dir, name)
+			relName := path.Join(rel, name)
+
+			if entry.IsDir() {
+				if !recursive {
+					continue
+				}
+				sub, found, err := h.settingsFor(path.Join(urlDir, name))
+				// Never fold a subtree with its own credentials into this manifest
+				if err != nil || !found || !sameCreds(sub.Creds, settings.Creds) {
+					continue
+				}
+				if err := walk(abs, path.Join(urlDir, name), relName, &sub); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			files = append(files, manifestFile{rel: relName, abs: abs, info: info})
+		}
+		return nil
+	}
+
+	if err := walk(dirPath, dirURL, "", settings); err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].rel < files[j].rel })
+	return files, nil
+}
+
+func sameCreds(a, b Credentials) bool {
+	if len(a) != len(b) || (a == nil) != (b == nil) {
+		return false
+	}
+	for user, hash := range a {
+		if b[user] != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// hashManifest hashes files with a bounded worker pool, reusing cached
+// digests. Output keeps the order of files.
+func (h *Handler) hashManifest(ctx context.Context, files []manifestFile) ([]ManifestEntry, error) {
+	entries := make([]ManifestEntry, len(files))
+	jobs := make(chan int)
+	errs := make(chan error, 1)
+
+	var wg sync.WaitGroup
+	for range min(runtime.NumCPU(), 4, max(len(files), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				f := files[i]
+				sum, err := h.hashes.sum(f.abs, f.info, "sha256")
+				if err != nil {
+					select {
+					case errs <- fmt.Errorf("%s: %w", f.rel, err):
+					default:
+					}
+					continue
+				}
+				entries[i] = ManifestEntry{Path: f.rel, Size: f.info.Size(), SHA256: sum}
+			}
+		}()
+	}
+
+feed:
+	for i := range files {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		case err := <-errs:
+			errs <- err
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+	return entries, nil
+}
+
+// isManifestRequest reports whether a missing path names the virtual
+// checksum file of an existing directory.
+func isManifestRequest(filePath string) bool {
+	if filepath.Base(filePath) != ManifestName {
+		return false
+	}
+	info, err := os.Stat(filepath.Dir(filePath))
+	return err == nil && info.IsDir()
+}
+
+// manifestDir returns the URL of the directory a SHA256SUMS request is for.
+func manifestDir(urlPath string) string {
+	dir := path.Dir(urlPath)
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	return dir
+}
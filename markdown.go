@@ -0,0 +1,137 @@
+package gosrvdir
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// maxMarkdownSize is the largest file rendered as Markdown; bigger files
+// are served raw.
+const maxMarkdownSize = 2 << 20
+
+// TOCEntry is one heading in a rendered document's table of contents.
+type TOCEntry struct {
+	Level int
+	ID    string
+	Title string
+}
+
+// The renderer runs in its default safe mode: raw HTML is dropped and
+// javascript: style link destinations are omitted.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+func isMarkdown(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// renderMarkdown converts Markdown to HTML. Relative links and images are
+// resolved against baseDir (a URL path), and links to other Markdown files
+// keep using the rendered view.
+func renderMarkdown(source []byte, baseDir string) (string, []TOCEntry, error) {
+	doc := markdown.Parser().Parse(text.NewReader(source))
+
+	var toc []TOCEntry
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			if id, ok := n.AttributeString("id"); ok {
+				toc = append(toc, TOCEntry{
+					Level: n.Level,
+					ID:    string(id.([]byte)),
+					Title: nodeText(n, source),
+				})
+			}
+		case *ast.Link:
+			n.Destination = resolveLink(n.Destination, baseDir, true)
+		case *ast.Image:
+			n.Destination = resolveLink(n.Destination, baseDir, false)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	var buf bytes.Buffer
+	if err := markdown.Renderer().Render(&buf, source, doc); err != nil {
+		return "", nil, err
+	}
+	return buf.String(), toc, nil
+}
+
+// nodeText returns the plain text below n, without inline markup.
+func nodeText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := c.(*ast.Text); ok && entering {
+			b.Write(t.Segment.Value(source))
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+func resolveLink(dest []byte, baseDir string, page bool) []byte {
+	u, err := url.Parse(string(dest))
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return dest
+	}
+	if !strings.HasPrefix(u.Path, "/") {
+		trailing := strings.HasSuffix(u.Path, "/")
+		u.Path = path.Join(baseDir, u.Path)
+		if trailing && u.Path != "/" {
+			u.Path += "/"
+		}
+	}
+	if page && isMarkdown(u.Path) && u.RawQuery == "" {
+		u.RawQuery = "view=render"
+	}
+	return []byte(u.String())
+}
+
+func (h *Handler) serveMarkdown(w http.ResponseWriter, r *http.Request, filePath, urlPath string, info os.FileInfo, settings *dirSettings) {
+	if info.Size() > maxMarkdownSize {
+		h.serveFile(w, r, filePath)
+		return
+	}
+
+	source, err := os.ReadFile(filePath)
+	if err != nil {
+		http.Error(w, "Cannot read file", http.StatusInternalServerError)
+		return
+	}
+
+	html, toc, err := renderMarkdown(source, path.Dir(urlPath))
+	if err != nil {
+		h.serveFile(w, r, filePath)
+		return
+	}
+
+	data := MarkdownData{
+		Path:  urlPath,
+		Theme: settings.Theme,
+		HTML:  html,
+		TOC:   toc,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	MarkdownPage(data).Render(w)
+}
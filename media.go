@@ -0,0 +1,109 @@
+package gosrvdir
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var (
+	audioExts = []string{".mp3", ".wav", ".flac", ".ogg", ".m4a", ".opus", ".aac"}
+	videoExts = []string{".mp4", ".mkv", ".avi", ".mov", ".webm", ".m4v"}
+)
+
+func isAudio(name string) bool {
+	return hasExt(name, audioExts)
+}
+
+func isVideo(name string) bool {
+	return hasExt(name, videoExts)
+}
+
+func isMedia(name string) bool {
+	return isAudio(name) || isVideo(name)
+}
+
+func hasExt(name string, exts []string) bool {
+	return slices.Contains(exts, strings.ToLower(filepath.Ext(name)))
+}
+
+// MediaItem is one entry of a player page's playlist.
+type MediaItem struct {
+	Name  string
+	URL   string
+	Video bool
+}
+
+func mediaItems(entries []FileInfo) []MediaItem {
+	var items []MediaItem
+	for _, e := range entries {
+		if e.IsDir || !isMedia(e.Name) {
+			continue
+		}
+		items = append(items, MediaItem{
+			Name:  e.Name,
+			URL:   href(e.Path, ""),
+			Video: isVideo(e.Name),
+		})
+	}
+	return items
+}
+
+func (h *Handler) servePlayer(w http.ResponseWriter, r *http.Request, urlPath string, settings *dirSettings) {
+	name := path.Base(urlPath)
+	if !isMedia(name) {
+		http.Error(w, "Not a media file", http.StatusNotFound)
+		return
+	}
+
+	data := PlayerData{
+		Path:  urlPath,
+		Theme: settings.Theme,
+		Items: []MediaItem{{Name: name, URL: href(urlPath, ""), Video: isVideo(name)}},
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	PlayerPage(data).Render(w)
+}
+
+func (h *Handler) servePlaylist(w http.ResponseWriter, r *http.Request, urlPath string, entries []FileInfo, settings *dirSettings) {
+	data := PlayerData{
+		Path:     urlPath,
+		Theme:    settings.Theme,
+		Items:    mediaItems(entries),
+		Playlist: true,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	PlayerPage(data).Render(w)
+}
+
+// serveM3U offers the directory's media as an extended M3U playlist with
+// absolute URLs, so players like VLC or mpv can stream it directly.
+func (h *Handler) serveM3U(w http.ResponseWriter, r *http.Request, urlPath string, entries []FileInfo) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for _, item := range mediaItems(entries) {
+		u := url.URL{Scheme: scheme, Host: r.Host, Path: strings.TrimSuffix(urlPath, "/") + "/" + item.Name}
+		fmt.Fprintf(&b, "#EXTINF:-1,%s\n%s\n", item.Name, u.String())
+	}
+
+	name := path.Base(urlPath)
+	if name == "/" || name == "." {
+		name = "gosrvdir"
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".m3u8"}))
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,179 @@
+package gosrvdir
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default Prometheus histogram buckets, in seconds.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics collects request statistics in Prometheus text format.
+// The zero value is ready to use.
+type Metrics struct {
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	listing  histogram
+
+	bytesServed     atomic.Uint64
+	activeDownloads atomic.Int64
+	authFailures    atomic.Uint64
+}
+
+type requestKey struct {
+	method string
+	code   int
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, non-cumulative; last entry is +Inf
+	sum    float64
+	total  uint64
+}
+
+func (m *Metrics) observeRequest(method string, code int, bytes int64) {
+	m.mu.Lock()
+	if m.requests == nil {
+		m.requests = make(map[requestKey]uint64)
+	}
+	m.requests[requestKey{metricMethod(method), code}]++
+	m.mu.Unlock()
+	m.bytesServed.Add(uint64(bytes))
+}
+
+// metricMethod maps methods other than the standard HTTP and WebDAV
+// ones to "other", so clients cannot add label values at will.
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodConnect,
+		http.MethodOptions, http.MethodTrace,
+		"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK":
+		return method
+	}
+	return "other"
+}
+
+func (m *Metrics) observeListing(d time.Duration) {
+	secs := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.listing.counts == nil {
+		m.listing.counts = make([]uint64, len(latencyBuckets)+1)
+	}
+	i := sort.SearchFloat64s(latencyBuckets, secs)
+	m.listing.counts[i]++
+	m.listing.sum += secs
+	m.listing.total++
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+
+	m.mu.Lock()
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].code < keys[j].code
+	})
+
+	fmt.Fprintln(cw, "# HELP gosrvdir_requests_total HTTP requests by method and status code.")
+	fmt.Fprintln(cw, "# TYPE gosrvdir_requests_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(cw, "gosrvdir_requests_total{method=%q,code=\"%d\"} %d\n", k.method, k.code, m.requests[k])
+	}
+
+	fmt.Fprintln(cw, "# HELP gosrvdir_listing_duration_seconds Time spent rendering directory listings.")
+	fmt.Fprintln(cw, "# TYPE gosrvdir_listing_duration_seconds histogram")
+	var cumulative uint64
+	for i, le := range latencyBuckets {
+		if m.listing.counts != nil {
+			cumulative += m.listing.counts[i]
+		}
+		fmt.Fprintf(cw, "gosrvdir_listing_duration_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(cw, "gosrvdir_listing_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.listing.total)
+	fmt.Fprintf(cw, "gosrvdir_listing_duration_seconds_sum %g\n", m.listing.sum)
+	fmt.Fprintf(cw, "gosrvdir_listing_duration_seconds_count %d\n", m.listing.total)
+	m.mu.Unlock()
+
+	fmt.Fprintln(cw, "# HELP gosrvdir_response_bytes_total Response body bytes sent.")
+	fmt.Fprintln(cw, "# TYPE gosrvdir_response_bytes_total counter")
+	fmt.Fprintf(cw, "gosrvdir_response_bytes_total %d\n", m.bytesServed.Load())
+
+	fmt.Fprintln(cw, "# HELP gosrvdir_active_downloads File downloads currently in progress.")
+	fmt.Fprintln(cw, "# TYPE gosrvdir_active_downloads gauge")
+	fmt.Fprintf(cw, "gosrvdir_active_downloads %d\n", m.activeDownloads.Load())
+
+	fmt.Fprintln(cw, "# HELP gosrvdir_auth_failures_total Rejected Basic Auth attempts.")
+	fmt.Fprintln(cw, "# TYPE gosrvdir_auth_failures_total counter")
+	fmt.Fprintf(cw, "gosrvdir_auth_failures_total %d\n", m.authFailures.Load())
+
+	return cw.n, cw.err
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// statusRecorder captures the status code and body size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	code  int
+	bytes int64
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += int64(n)
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,183 @@
+package gosrvdir
+
+import (
+	"cmp"
+	"container/heap"
+	"errors"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	listingPerPage    = 1000  // entries per page unless ?per_page= says otherwise
+	listingMaxPerPage = 10000 // upper bound for ?per_page=
+	listingBatch      = 1024  // entries read from a directory at a time
+)
+
+// Pagination is the slice of a listing shown on one page.
+type Pagination struct {
+	Page    int // 1-based
+	PerPage int
+	Total   int        // visible entries in the directory
+	Query   url.Values // parameters other than page to keep in page links
+}
+
+// Count is the number of pages, at least one.
+func (p Pagination) Count() int {
+	if p.PerPage <= 0 {
+		return 1
+	}
+	return max(1, (p.Total+p.PerPage-1)/p.PerPage)
+}
+
+func parsePagination(q url.Values) Pagination {
+	p := Pagination{Page: 1, PerPage: listingPerPage, Query: url.Values{}}
+	if n, err := strconv.Atoi(q.Get("page")); err == nil && n > 0 {
+		p.Page = n
+	}
+	if n, err := strconv.Atoi(q.Get("per_page")); err == nil && n > 0 {
+		p.PerPage = min(n, listingMaxPerPage)
+	}
+	for key, values := range q {
+		if key != "page" {
+			p.Query[key] = values
+		}
+	}
+	return p
+}
+
+// readPage returns one page of a directory's visible entries and whether
+// the directory has any media. In name order the directory is read in
+// batches, keeping only the entries up to the end of the page, and only
+// the page is stat'ed: memory grows with the page number rather than the
+// size of the directory.
+func (h *Handler) readPage(filePath, urlPath string, settings *dirSettings, p *Pagination) ([]FileInfo, bool, error) {
+	desc := strings.HasPrefix(settings.Sort, "-")
+	if key := strings.TrimPrefix(settings.Sort, "-"); key == "" || key == "name" {
+		entries, total, hasMedia, err := readNamePage(filePath, settings, *p, desc)
+		if err != nil {
+			return nil, false, err
+		}
+		p.Total = total
+		return h.entryInfos(filePath, urlPath, entries, settings), hasMedia, nil
+	}
+
+	entries, err := visibleEntries(filePath, settings)
+	if err != nil {
+		return nil, false, err
+	}
+	hasMedia := slices.ContainsFunc(entries, func(e os.DirEntry) bool { return !e.IsDir() && isMedia(e.Name()) })
+	p.Total = len(entries)
+
+	files := h.entryInfos(filePath, urlPath, entries, settings)
+	sortEntries(files, settings.Sort)
+	from, to := pageBounds(*p, len(files))
+	return files[from:to], hasMedia, nil
+}
+
+// readNamePage returns page p of the visible entries in name order, the
+// number of visible entries and whether any is media.
+func readNamePage(filePath string, settings *dirSettings, p Pagination, desc bool) (page []os.DirEntry, total int, hasMedia bool, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer f.Close()
+
+	keep := math.MaxInt
+	if p.Page <= math.MaxInt/p.PerPage {
+		keep = p.Page * p.PerPage
+	}
+	// A max-heap of the first entries so far, the last of them on top
+	first := &nameHeap{desc: desc}
+	for {
+		batch, err := f.ReadDir(listingBatch)
+		for _, e := range batch {
+			if settings.hidden(e.Name()) {
+				continue
+			}
+			total++
+			hasMedia = hasMedia || (!e.IsDir() && isMedia(e.Name()))
+
+			k := newNameKey(e)
+			if first.Len() < keep {
+				heap.Push(first, k)
+			} else if compareNames(k, first.keys[0], desc) < 0 {
+				first.keys[0] = k
+				heap.Fix(first, 0)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, 0, false, err
+		}
+	}
+
+	slices.SortFunc(first.keys, func(a, b nameKey) int { return compareNames(a, b, desc) })
+	from, to := pageBounds(p, total)
+	for _, k := range first.keys[from:to] {
+		page = append(page, k.entry)
+	}
+	return page, total, hasMedia, nil
+}
+
+func pageBounds(p Pagination, n int) (from, to int) {
+	if p.Page-1 > n/p.PerPage {
+		return n, n
+	}
+	from = min((p.Page-1)*p.PerPage, n)
+	return from, min(from+p.PerPage, n)
+}
+
+// nameKey is an entry with its sort key: the lowercased name, with a "/"
+// suffix for directories, computed once rather than per comparison.
+type nameKey struct {
+	key   string
+	entry os.DirEntry
+}
+
+func newNameKey(e os.DirEntry) nameKey {
+	key := strings.ToLower(e.Name())
+	if e.IsDir() {
+		key += "/"
+	}
+	return nameKey{key, e}
+}
+
+// compareNames orders like sortEntries does by name: directories first,
+// then case-insensitively with their "/" suffix.
+func compareNames(a, b nameKey, desc bool) int {
+	if a.entry.IsDir() != b.entry.IsDir() {
+		if a.entry.IsDir() {
+			return -1
+		}
+		return 1
+	}
+	if desc {
+		a, b = b, a
+	}
+	// Names differing only in case keep a fixed order across pages
+	return cmp.Or(strings.Compare(a.key, b.key), strings.Compare(a.entry.Name(), b.entry.Name()))
+}
+
+// nameHeap keeps the entry that sorts last on top.
+type nameHeap struct {
+	keys []nameKey
+	desc bool
+}
+
+func (h *nameHeap) Len() int           { return len(h.keys) }
+func (h *nameHeap) Less(i, j int) bool { return compareNames(h.keys[i], h.keys[j], h.desc) > 0 }
+func (h *nameHeap) Swap(i, j int)      { h.keys[i], h.keys[j] = h.keys[j], h.keys[i] }
+func (h *nameHeap) Push(x any)         { h.keys = append(h.keys, x.(nameKey)) }
+func (h *nameHeap) Pop() any {
+	k := h.keys[len(h.keys)-1]
+	h.keys = h.keys[:len(h.keys)-1]
+	return k
+}
@@ -0,0 +1,186 @@
+package gosrvdir
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPageBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     int
+		perPage  int
+		n        int
+		from, to int
+	}{
+		{"first page", 1, 10, 25, 0, 10},
+		{"last partial page", 3, 10, 25, 20, 25},
+		{"exact fit", 2, 10, 20, 10, 20},
+		{"out of range", 4, 10, 25, 25, 25},
+		{"empty directory", 1, 10, 0, 0, 0},
+		{"huge page number", int(^uint(0) >> 1), 1000, 25, 25, 25},
+		{"huge page, one per page", int(^uint(0) >> 1), 1, 25, 25, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := pageBounds(Pagination{Page: tt.page, PerPage: tt.perPage}, tt.n)
+			if from != tt.from || to != tt.to {
+				t.Errorf("pageBounds = %d, %d; want %d, %d", from, to, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query   string
+		page    int
+		perPage int
+	}{
+		{"", 1, listingPerPage},
+		{"page=3&per_page=50", 3, 50},
+		{"page=0&per_page=-5", 1, listingPerPage},
+		{"page=x&per_page=y", 1, listingPerPage},
+		{"per_page=1000000", 1, listingMaxPerPage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, _ := url.ParseQuery(tt.query)
+			p := parsePagination(q)
+			if p.Page != tt.page || p.PerPage != tt.perPage {
+				t.Errorf("page %d, per_page %d; want %d, %d", p.Page, p.PerPage, tt.page, tt.perPage)
+			}
+			if p.Query.Has("page") {
+				t.Error("page kept in Query")
+			}
+		})
+	}
+}
+
+func TestListingPageOutOfRange(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 5 {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d", i)), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &Handler{Dir: dir, Theme: "auto"}
+
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"", http.StatusOK},
+		{"?per_page=2&page=3", http.StatusOK},
+		{"?per_page=2&page=4", http.StatusNotFound},
+		{"?page=9223372036854775807", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+tt.query, nil))
+			if rec.Code != tt.code {
+				t.Errorf("status %d, want %d", rec.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestReadNamePage(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"b.txt", "A.txt", "c.mp3", "a.txt", "Zed", "_x", "10", "9", ".hidden", "ä"}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"dirB", "dira", "Dir"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	settings := &dirSettings{Hide: []string{".hidden"}}
+	h := &Handler{Dir: dir}
+
+	for _, sort := range []string{"name", "-name"} {
+		// The reference: every entry stat'ed and sorted
+		all, err := visibleEntries(dir, settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := h.entryInfos(dir, "/", all, settings)
+		sortEntries(want, sort)
+
+		for perPage := 1; perPage <= len(want)+1; perPage++ {
+			for page := 1; page <= len(want)/perPage+2; page++ {
+				p := Pagination{Page: page, PerPage: perPage}
+				got, total, hasMedia, err := readNamePage(dir, settings, p, sort == "-name")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if total != len(want) || !hasMedia {
+					t.Fatalf("total %d, media %v; want %d, true", total, hasMedia, len(want))
+				}
+				from, to := pageBounds(p, len(want))
+				if len(got) != to-from {
+					t.Fatalf("%s page %d/%d: %d entries, want %d", sort, page, perPage, len(got), to-from)
+				}
+				seen := map[string]bool{}
+				for i, e := range got {
+					if seen[e.Name()] {
+						t.Errorf("%s page %d/%d repeats %s", sort, page, perPage, e.Name())
+					}
+					seen[e.Name()] = true
+					// Names differing only in case may tie in the reference
+					if !strings.EqualFold(e.Name(), strings.TrimSuffix(want[from+i].Name, "/")) {
+						t.Errorf("%s page %d/%d entry %d = %s, want %s", sort, page, perPage, i, e.Name(), want[from+i].Name)
+					}
+				}
+			}
+		}
+	}
+}
+
+// discardWriter is a ResponseWriter that keeps nothing, so benchmarks
+// measure the listing rather than a buffered body.
+type discardWriter struct{ header http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+func benchmarkListing(b *testing.B, sort, query string) {
+	dir := b.TempDir()
+	for i := range 100_000 {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("file-%06d.txt", i)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		f.Close()
+	}
+	if sort != "" {
+		if err := os.WriteFile(filepath.Join(dir, DirConfigName), []byte("sort = \""+sort+"\"\n"), 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	h := &Handler{Dir: dir, Theme: "auto"}
+	r := httptest.NewRequest(http.MethodGet, "/"+query, nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		w := &discardWriter{header: http.Header{}}
+		h.ServeHTTP(w, r)
+	}
+}
+
+// Memory in name order grows with the page number, not the directory
+func BenchmarkListing100kByNameFirstPage(b *testing.B) { benchmarkListing(b, "", "") }
+func BenchmarkListing100kByName(b *testing.B)          { benchmarkListing(b, "", "?page=50") }
+func BenchmarkListing100kByDate(b *testing.B)          { benchmarkListing(b, "-date", "?page=50") }
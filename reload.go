@@ -0,0 +1,134 @@
+package gosrvdir
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadQuiet is how long the tree must stay unchanged before pages
+// reload, so a rebuild rewriting many files causes a single reload.
+const reloadQuiet = 300 * time.Millisecond
+
+// liveReload tells connected pages to reload after files below the root
+// change. The zero value is ready to use; the watcher starts with the
+// first subscriber.
+type liveReload struct {
+	once    sync.Once
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+func (l *liveReload) subscribe(root string) (<-chan struct{}, func()) {
+	l.once.Do(func() { go l.run(root) })
+
+	ch := make(chan struct{}, 1)
+	l.mu.Lock()
+	if l.clients == nil {
+		l.clients = make(map[chan struct{}]bool)
+	}
+	l.clients[ch] = true
+	l.mu.Unlock()
+
+	return ch, func() {
+		l.mu.Lock()
+		delete(l.clients, ch)
+		l.mu.Unlock()
+	}
+}
+
+func (l *liveReload) run(root string) {
+	changes := watchTree(context.Background(), root)
+	var quiet <-chan time.Time
+	for {
+		select {
+		case <-changes:
+			quiet = time.After(reloadQuiet)
+		case <-quiet:
+			quiet = nil
+			l.broadcast()
+		}
+	}
+}
+
+func (l *liveReload) broadcast() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.clients {
+		select {
+		case ch <- struct{}{}:
+		default: // a reload is already pending
+		}
+	}
+}
+
+// serveLiveReload streams a "reload" event whenever the served tree changes.
+func (h *Handler) serveLiveReload(w http.ResponseWriter, r *http.Request) {
+	if h.watchers.Add(1) > maxWatchers {
+		h.watchers.Add(-1)
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Too many watchers", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.watchers.Add(-1)
+
+	reloads, unsubscribe := h.reload.subscribe(h.Dir)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	fmt.Fprint(w, "retry: 1000\n\n")
+	if rc.Flush() != nil {
+		return
+	}
+
+	ping := time.NewTicker(eventsKeepAlive)
+	defer ping.Stop()
+	for {
+		select {
+		case <-reloads:
+			fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// serveHTMLWithReload serves an HTML file with the live-reload client
+// injected before </body>, or appended when there is none.
+func (h *Handler) serveHTMLWithReload(w http.ResponseWriter, r *http.Request, filePath string, info os.FileInfo) {
+	page, err := os.ReadFile(filePath)
+	if err != nil {
+		http.Error(w, "Cannot read file", http.StatusInternalServerError)
+		return
+	}
+
+	script := []byte("<script>" + jsLiveReload + "</script>")
+	if i := bytes.LastIndex(bytes.ToLower(page), []byte("</body>")); i >= 0 {
+		page = append(page[:i:i], append(script, page[i:]...)...)
+	} else {
+		page = append(page, script...)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), bytes.NewReader(page))
+}
+
+func isHTML(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".html" || ext == ".htm"
+}
@@ -2,23 +2,54 @@ package gosrvdir
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Config struct {
-	Host     string
-	Port     int
-	Dir      string
-	Theme    string
-	Auth     string
-	AuthFile string
+	Version  string `toml:"-"`
+	Host     string `toml:"host"`
+	Port     int    `toml:"port"`
+	Dir      string `toml:"dir"`
+	Theme    string `toml:"theme"`
+	Auth     string `toml:"auth"`
+	AuthFile string `toml:"auth_file"`
+
+	Allow           []string `toml:"allow"`
+	Deny            []string `toml:"deny"`
+	AllowBypassAuth bool     `toml:"allow_bypass_auth"`
+
+	Metrics     bool   `toml:"metrics"`
+	MetricsAddr string `toml:"metrics_addr"`
+
+	RenderMarkdown bool `toml:"render_markdown"`
+	DirSizes       bool `toml:"dir_sizes"`
+
+	Index     bool   `toml:"index"`
+	SPA       string `toml:"spa"` // fallback page, relative to Dir
+	CleanURLs bool   `toml:"clean_urls"`
+
+	LiveReload bool `toml:"livereload"`
+	Compress   bool `toml:"compress"`
+
+	CacheFiles    string `toml:"cache_files"`    // Cache-Control for files
+	CacheListings string `toml:"cache_listings"` // Cache-Control for listings
+
+	WriteUsers     []string      `toml:"write_users"`
+	TrashRetention time.Duration `toml:"trash_retention"`
+	WebDAV         bool          `toml:"webdav"`
 }
 
 func Serve(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
 	absDir, err := filepath.Abs(cfg.Dir)
 	if err != nil {
 		return fmt.Errorf("cannot resolve path: %w", err)
@@ -27,9 +58,6 @@ func Serve(cfg Config) error {
 	var creds Credentials
 	if cfg.Auth != "" {
 		parts := strings.SplitN(cfg.Auth, ":", 2)
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid --auth format, expected user:password")
-		}
 		hash, err := bcrypt.GenerateFromPassword([]byte(parts[1]), bcrypt.DefaultCost)
 		if err != nil {
 			return fmt.Errorf("hashing password: %w", err)
@@ -43,14 +71,60 @@ func Serve(cfg Config) error {
 		}
 	}
 
+	// CIDRs were checked by Validate
+	var filter *IPFilter
+	if len(cfg.Allow) > 0 || len(cfg.Deny) > 0 {
+		allow, _ := ParseCIDRs(cfg.Allow)
+		deny, _ := ParseCIDRs(cfg.Deny)
+		filter = &IPFilter{Allow: allow, Deny: deny, BypassAuth: cfg.AllowBypassAuth}
+	}
+
 	handler := &Handler{
-		Dir:   absDir,
-		Theme: cfg.Theme,
-		Creds: creds,
+		Dir:     absDir,
+		Theme:   cfg.Theme,
+		Creds:   creds,
+		Filter:  filter,
+		Version: cfg.Version,
+		Started: time.Now(),
+
+		RenderMarkdown: cfg.RenderMarkdown,
+		DirSizes:       cfg.DirSizes,
+		Index:          cfg.Index,
+		CleanURLs:      cfg.CleanURLs,
+		LiveReload:     cfg.LiveReload,
+		Compress:       cfg.Compress,
+		FileCache:      cfg.CacheFiles,
+		ListingCache:   cfg.CacheListings,
+		WriteUsers:     cfg.WriteUsers,
+		TrashRetention: cfg.TrashRetention,
+		WebDAV:         cfg.WebDAV,
+	}
+	if cfg.SPA != "" {
+		handler.SPAFallback = "/" + filepath.ToSlash(filepath.Clean(cfg.SPA))
+	}
+
+	if cfg.Metrics || cfg.MetricsAddr != "" {
+		handler.Metrics = &Metrics{}
+		handler.ServeMetrics = cfg.MetricsAddr == ""
+	}
+
+	if len(cfg.WriteUsers) > 0 && cfg.TrashRetention > 0 {
+		go handler.purgeExpired(time.Hour)
 	}
 
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	fmt.Printf("Serving %s at http://%s\n", absDir, addr)
 
+	if cfg.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", handler.Metrics)
+		fmt.Printf("Metrics at http://%s/metrics\n", cfg.MetricsAddr)
+		go func() {
+			if err := http.ListenAndServe(cfg.MetricsAddr, mux); err != nil {
+				log.Printf("metrics listener: %v", err)
+			}
+		}()
+	}
+
 	return http.ListenAndServe(addr, handler)
 }
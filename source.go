@@ -0,0 +1,137 @@
+package gosrvdir
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// maxSourceSize is the largest file rendered by the source viewer; bigger
+// files are served raw.
+const maxSourceSize = 1 << 20
+
+// chromaStyles maps gosrvdir themes to chroma styles. "auto" switches to
+// its dark counterpart via prefers-color-scheme.
+var chromaStyles = map[string]string{
+	"auto":      "github",
+	"nord":      "nord",
+	"squirrel":  "github",
+	"archlinux": "onedark",
+	"monokai":   "monokai",
+	"zenburn":   "gruvbox",
+}
+
+const chromaAutoDark = "nord"
+
+// sourceExts are the code files linked to the source viewer from listings.
+var sourceExts = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".ts": true, ".rs": true,
+	".c": true, ".cpp": true, ".h": true, ".java": true, ".rb": true,
+	".php": true, ".sh": true, ".fish": true,
+}
+
+func isSourceFile(name string) bool {
+	return sourceExts[strings.ToLower(filepath.Ext(name))]
+}
+
+func (h *Handler) serveSource(w http.ResponseWriter, r *http.Request, filePath, urlPath string, info os.FileInfo, settings *dirSettings) {
+	if info.Size() > maxSourceSize {
+		h.serveFile(w, r, filePath)
+		return
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		http.Error(w, "Cannot read file", http.StatusInternalServerError)
+		return
+	}
+	if isBinary(content) {
+		h.serveFile(w, r, filePath)
+		return
+	}
+
+	code, err := highlight(filepath.Base(filePath), string(content))
+	if err != nil {
+		h.serveFile(w, r, filePath)
+		return
+	}
+
+	data := SourceData{
+		Path:  urlPath,
+		Theme: settings.Theme,
+		Code:  code,
+		Lines: bytes.Count(content, []byte("\n")),
+		Size:  formatSize(info.Size()),
+	}
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		data.Lines++
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	SourcePage(data).Render(w)
+}
+
+// isBinary guesses whether content is binary by looking for NUL bytes.
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0
+}
+
+var sourceFormatter = html.New(
+	html.WithClasses(true),
+	html.WithLineNumbers(true),
+	html.WithLinkableLineNumbers(true, "L"),
+)
+
+func highlight(name, content string) (string, error) {
+	lexer := lexers.Match(name)
+	if lexer == nil {
+		lexer = lexers.Analyse(content)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	it, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := sourceFormatter.Format(&buf, styles.Fallback, it); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+var chromaCSS = sync.OnceValue(func() string {
+	var buf bytes.Buffer
+	for _, t := range themes {
+		writeChromaCSS(&buf, chromaStyles[t.value], `[data-theme="`+t.value+`"] `)
+	}
+	buf.WriteString("@media (prefers-color-scheme: dark) {\n")
+	writeChromaCSS(&buf, chromaAutoDark, `[data-theme="auto"] `)
+	buf.WriteString("}\n")
+	return buf.String()
+})
+
+// writeChromaCSS writes a chroma style's classes scoped to one theme.
+func writeChromaCSS(buf *bytes.Buffer, style, scope string) {
+	var css bytes.Buffer
+	sourceFormatter.WriteCSS(&css, styles.Get(style))
+	for _, line := range strings.Split(css.String(), "\n") {
+		if !strings.Contains(line, ".chroma") {
+			continue
+		}
+		buf.WriteString(strings.Replace(line, ".chroma", scope+".chroma", 1))
+		buf.WriteByte('\n')
+	}
+}
@@ -0,0 +1,67 @@
+package gosrvdir
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// indexFiles are served for directory URLs with Index, in this order.
+var indexFiles = []string{"index.html", "index.htm"}
+
+// findIndex returns a directory's index page, if it has one.
+func findIndex(dirPath string, settings *dirSettings) (string, bool) {
+	for _, name := range indexFiles {
+		if settings.ignored(name) {
+			continue
+		}
+		p := filepath.Join(dirPath, name)
+		if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// listingQuery reports whether a directory request asks for one of
+// gosrvdir's own views rather than the site's index page.
+func listingQuery(q url.Values) bool {
+	for _, key := range []string{"view", "format", "manifest", "events"} {
+		if q.Has(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveMissing maps a path that does not exist to the file to serve
+// instead: "/about" to "about.html" with CleanURLs, or the SPA fallback
+// for extensionless paths. settings are those of the missing path.
+func (h *Handler) resolveMissing(r *http.Request, urlPath string, settings *dirSettings) (string, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return "", false
+	}
+	if strings.HasPrefix(urlPath+"/", reservedPrefix) {
+		return "", false
+	}
+
+	if h.CleanURLs && urlPath != "/" && path.Ext(urlPath) == "" {
+		name := path.Base(urlPath) + ".html"
+		p := filepath.Join(h.Dir, filepath.FromSlash(urlPath)+".html")
+		if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() && !settings.ignored(name) {
+			return p, true
+		}
+	}
+
+	if h.SPAFallback != "" && path.Ext(urlPath) == "" {
+		// Never hand out a file protected by other credentials
+		fallback, found, err := h.settingsFor(h.SPAFallback)
+		if err == nil && found && sameCreds(fallback.Creds, settings.Creds) {
+			return filepath.Join(h.Dir, filepath.FromSlash(h.SPAFallback)), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,177 @@
+package gosrvdir
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	thumbSize      = 240      // bounding box edge in pixels
+	thumbCacheMax  = 64 << 20 // bytes of encoded thumbnails kept in memory
+	maxThumbPixels = 50e6     // refuse to decode larger images
+)
+
+// thumbSem bounds concurrent decodes, which are CPU and memory heavy.
+var thumbSem = make(chan struct{}, 4)
+
+// thumbnailable reports whether a thumbnail can be generated server-side.
+func thumbnailable(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// isImage reports whether browsers can display the file in an <img>.
+func isImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".svg", ".webp", ".ico":
+		return true
+	}
+	return false
+}
+
+func (h *Handler) serveThumbnail(w http.ResponseWriter, r *http.Request, filePath string, info os.FileInfo) {
+	if !thumbnailable(filePath) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	key := fmt.Sprintf("%s|%d|%d", filePath, info.ModTime().UnixNano(), info.Size())
+	thumb, ok := h.thumbs.get(key)
+	if !ok {
+		var err error
+		thumbSem <- struct{}{}
+		thumb, err = makeThumbnail(filePath)
+		<-thumbSem
+		if err != nil {
+			http.Error(w, "Cannot create thumbnail", http.StatusUnsupportedMediaType)
+			return
+		}
+		h.thumbs.add(key, thumb)
+	}
+
+	w.Header().Set("Content-Type", thumb.contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(w, r, "", info.ModTime(), bytes.NewReader(thumb.data))
+}
+
+type thumbnail struct {
+	data        []byte
+	contentType string
+}
+
+func makeThumbnail(filePath string) (thumbnail, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return thumbnail{}, err
+	}
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return thumbnail{}, err
+	}
+	if float64(cfg.Width)*float64(cfg.Height) > maxThumbPixels {
+		return thumbnail{}, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return thumbnail{}, err
+	}
+
+	var src image.Image
+	switch format {
+	case "jpeg":
+		src, err = jpeg.Decode(f)
+	case "png":
+		src, err = png.Decode(f)
+	case "gif":
+		src, err = gif.Decode(f)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	if err != nil {
+		return thumbnail{}, err
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > thumbSize || h > thumbSize {
+		if w >= h {
+			w, h = thumbSize, max(1, h*thumbSize/w)
+		} else {
+			w, h = max(1, w*thumbSize/h), thumbSize
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80})
+		return thumbnail{buf.Bytes(), "image/jpeg"}, err
+	}
+	// PNG keeps transparency from PNG and GIF sources
+	err = png.Encode(&buf, dst)
+	return thumbnail{buf.Bytes(), "image/png"}, err
+}
+
+// thumbCache is an LRU of encoded thumbnails bounded by total size.
+// The zero value is ready to use.
+type thumbCache struct {
+	mu    sync.Mutex
+	size  int64
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type thumbCacheItem struct {
+	key   string
+	thumb thumbnail
+}
+
+func (c *thumbCache) get(key string) (thumbnail, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return thumbnail{}, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*thumbCacheItem).thumb, true
+}
+
+func (c *thumbCache) add(key string, t thumbnail) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.items == nil {
+		c.items = make(map[string]*list.Element)
+		c.ll = list.New()
+	}
+	if _, ok := c.items[key]; ok {
+		return
+	}
+	c.items[key] = c.ll.PushFront(&thumbCacheItem{key, t})
+	c.size += int64(len(t.data))
+
+	for c.size > thumbCacheMax && c.ll.Len() > 1 {
+		el := c.ll.Back()
+		item := el.Value.(*thumbCacheItem)
+		c.ll.Remove(el)
+		delete(c.items, item.key)
+		c.size -= int64(len(item.thumb.data))
+	}
+}
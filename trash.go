@@ -0,0 +1,233 @@
+package gosrvdir
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// TrashName is the directory below the root that deleted entries are
+// moved to. Like DirConfigName it is never listed or served.
+const TrashName = ".gosrvdir-trash"
+
+var trashID = regexp.MustCompile(`^[0-9]+-[0-9a-f]{8}$`)
+
+// TrashItem is a deleted entry, stored as <ID> in the trash directory
+// with its metadata in <ID>.toml.
+type TrashItem struct {
+	ID      string    `toml:"-"`
+	Path    string    `toml:"path"` // original URL path
+	Deleted time.Time `toml:"deleted"`
+	User    string    `toml:"user"`
+	IsDir   bool      `toml:"dir"`
+	Size    string    `toml:"-"`
+	Expires time.Time `toml:"-"`
+}
+
+func (h *Handler) trashDir() string {
+	return filepath.Join(h.Dir, TrashName)
+}
+
+// moveToTrash moves the entry at filePath (URL urlPath) into the trash.
+func (h *Handler) moveToTrash(filePath, urlPath, user string) error {
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(h.trashDir(), 0o700); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	id := fmt.Sprintf("%d-%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
+	item := TrashItem{Path: urlPath, Deleted: time.Now().UTC(), User: user, IsDir: info.IsDir()}
+
+	meta, err := os.Create(filepath.Join(h.trashDir(), id+".toml"))
+	if err != nil {
+		return err
+	}
+	err = toml.NewEncoder(meta).Encode(item)
+	if cerr := meta.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(filePath, filepath.Join(h.trashDir(), id))
+	}
+	if errors.Is(err, syscall.EXDEV) {
+		os.Remove(meta.Name())
+		return &writeError{http.StatusConflict, "Cannot move " + path.Base(urlPath) + " to the trash, which is on another filesystem"}
+	} else if err != nil {
+		os.Remove(meta.Name())
+		return fmt.Errorf("moving %s to trash: %w", urlPath, err)
+	}
+	return nil
+}
+
+// trashItems lists the trash, most recently deleted first.
+func (h *Handler) trashItems() ([]TrashItem, error) {
+	entries, err := os.ReadDir(h.trashDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var items []TrashItem
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".toml")
+		if !ok || !trashID.MatchString(id) {
+			continue
+		}
+		item, err := h.trashItem(id)
+		if err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Deleted.After(items[j].Deleted) })
+	return items, nil
+}
+
+func (h *Handler) trashItem(id string) (TrashItem, error) {
+	var item TrashItem
+	if !trashID.MatchString(id) {
+		return item, fs.ErrNotExist
+	}
+	if _, err := toml.DecodeFile(filepath.Join(h.trashDir(), id+".toml"), &item); err != nil {
+		return item, err
+	}
+	info, err := os.Lstat(filepath.Join(h.trashDir(), id))
+	if err != nil {
+		return item, err
+	}
+	item.ID = id
+	item.Expires = item.Deleted.Add(h.TrashRetention)
+	if !info.IsDir() {
+		item.Size = formatSize(info.Size())
+	}
+	return item, nil
+}
+
+// restoreTrash moves an item back to where it was, recreating missing
+// parent directories but never replacing what is there now.
+func (h *Handler) restoreTrash(item TrashItem) error {
+	dst := filepath.Join(h.Dir, filepath.FromSlash(item.Path))
+	if !h.insideRoot(filepath.Dir(dst)) {
+		return &writeError{http.StatusForbidden, "Cannot restore " + item.Path + " there"}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := renameNoReplace(filepath.Join(h.trashDir(), item.ID), dst); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(h.trashDir(), item.ID+".toml"))
+}
+
+func (h *Handler) purgeTrash(id string) error {
+	if err := os.RemoveAll(filepath.Join(h.trashDir(), id)); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(h.trashDir(), id+".toml"))
+}
+
+// purgeExpired deletes items older than TrashRetention, checking every
+// interval. It never returns.
+func (h *Handler) purgeExpired(interval time.Duration) {
+	for {
+		items, err := h.trashItems()
+		if err != nil {
+			log.Printf("trash: %v", err)
+		}
+		for _, item := range items {
+			if time.Now().After(item.Expires) {
+				if err := h.purgeTrash(item.ID); err != nil {
+					log.Printf("trash: purging %s: %v", item.Path, err)
+				}
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// serveTrash shows the trash to users who may write and handles its
+// ?restore and ?purge POSTs. Items from subtrees with credentials other
+// than the root's are left out.
+func (h *Handler) serveTrash(w http.ResponseWriter, r *http.Request, settings *dirSettings) {
+	user, ok := h.writeUser(r)
+	if !ok || h.TrashRetention <= 0 {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	visible := func(item TrashItem) bool {
+		parent, _, err := h.settingsFor(path.Dir(item.Path))
+		return err == nil && sameCreds(parent.Creds, settings.Creds)
+	}
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		if !sameOrigin(r) || !h.csrf.valid(user, r.PostForm.Get("csrf")) {
+			http.Error(w, "Invalid or expired form token, reload the page", http.StatusForbidden)
+			return
+		}
+		for _, id := range r.PostForm["id"] {
+			item, err := h.trashItem(id)
+			if err != nil || !visible(item) {
+				http.Error(w, "Not Found", http.StatusNotFound)
+				return
+			}
+			if r.URL.Query().Has("restore") {
+				err = h.restoreTrash(item)
+			} else if r.URL.Query().Has("purge") {
+				err = h.purgeTrash(item.ID)
+			}
+			var we *writeError
+			if errors.As(err, &we) {
+				http.Error(w, we.msg, we.code)
+				return
+			} else if err != nil {
+				log.Printf("trash by %s: %s: %v", user, item.Path, err)
+				http.Error(w, "Operation failed", http.StatusInternalServerError)
+				return
+			}
+			log.Printf("%s: %s %s", user, r.URL.RawQuery, item.Path)
+		}
+		http.Redirect(w, r, reservedPrefix+"trash", http.StatusSeeOther)
+		return
+	}
+
+	items, err := h.trashItems()
+	if err != nil {
+		http.Error(w, "Cannot read trash", http.StatusInternalServerError)
+		return
+	}
+	data := TrashData{Theme: settings.Theme, CSRF: h.csrf.token(user), Retention: h.TrashRetention}
+	for _, item := range items {
+		if visible(item) {
+			data.Items = append(data.Items, item)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	TrashPage(data).Render(w)
+}
@@ -0,0 +1,115 @@
+package gosrvdir
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// trashOnly returns the single item in h's trash.
+func trashOnly(t *testing.T, h *Handler) TrashItem {
+	t.Helper()
+	items, err := h.trashItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("trash = %+v, want one item", items)
+	}
+	return items[0]
+}
+
+func TestTrashRestore(t *testing.T) {
+	h, dir, _ := writeHandler(t)
+	h.TrashRetention = time.Hour
+	writeTree(t, dir, map[string]string{"sub/deep/c.txt": "c"})
+
+	expectStatus(t, postWrite(h, "/sub/deep/", "delete", "alice", url.Values{"name": {"c.txt"}}), http.StatusSeeOther)
+	expectMissing(t, filepath.Join(dir, "sub", "deep", "c.txt"))
+	item := trashOnly(t, h)
+	if item.Path != "/sub/deep/c.txt" || item.User != "alice" || item.IsDir {
+		t.Errorf("item = %+v", item)
+	}
+
+	rec := get(h, reservedPrefix+"trash", "alice")
+	expectStatus(t, rec, http.StatusOK)
+	if body := rec.Body.String(); !strings.Contains(body, item.ID) || !strings.Contains(body, `href="/sub/deep/"`) {
+		t.Errorf("trash page does not list the item:\n%s", body)
+	}
+	expectStatus(t, get(h, reservedPrefix+"trash", "bob"), http.StatusNotFound)
+
+	// Missing parents are recreated
+	if err := os.RemoveAll(filepath.Join(dir, "sub", "deep")); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, postWrite(h, reservedPrefix+"trash", "restore", "alice", url.Values{"id": {item.ID}}), http.StatusSeeOther)
+	expectFile(t, filepath.Join(dir, "sub", "deep", "c.txt"), "c")
+	if items, _ := h.trashItems(); len(items) != 0 {
+		t.Errorf("trash after restore = %+v", items)
+	}
+
+	// Restoring never replaces what is there now
+	expectStatus(t, postWrite(h, "/sub/", "delete", "alice", url.Values{"name": {"b.txt"}}), http.StatusSeeOther)
+	writeTree(t, dir, map[string]string{"sub/b.txt": "new"})
+	item = trashOnly(t, h)
+	expectStatus(t, postWrite(h, reservedPrefix+"trash", "restore", "alice", url.Values{"id": {item.ID}}), http.StatusConflict)
+	expectFile(t, filepath.Join(dir, "sub", "b.txt"), "new")
+
+	expectStatus(t, postWrite(h, reservedPrefix+"trash", "purge", "alice", url.Values{"id": {item.ID}}), http.StatusSeeOther)
+	if items, _ := h.trashItems(); len(items) != 0 {
+		t.Errorf("trash after purge = %+v", items)
+	}
+	expectStatus(t, postWrite(h, reservedPrefix+"trash", "purge", "alice", url.Values{"id": {item.ID}}), http.StatusNotFound)
+}
+
+func TestTrashRestoreThroughSymlink(t *testing.T) {
+	h, dir, outside := writeHandler(t)
+	h.TrashRetention = time.Hour
+
+	expectStatus(t, postWrite(h, "/sub/", "delete", "alice", url.Values{"name": {"b.txt"}}), http.StatusSeeOther)
+	item := trashOnly(t, h)
+
+	// Meanwhile sub/ became a link out of the root
+	if err := os.RemoveAll(filepath.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, postWrite(h, reservedPrefix+"trash", "restore", "alice", url.Values{"id": {item.ID}}), http.StatusForbidden)
+	expectMissing(t, filepath.Join(outside, "b.txt"))
+	trashOnly(t, h)
+
+	// A link to a directory that does not exist yet is no way out either
+	if err := os.Remove(filepath.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "later"), filepath.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, postWrite(h, reservedPrefix+"trash", "restore", "alice", url.Values{"id": {item.ID}}), http.StatusForbidden)
+	expectMissing(t, filepath.Join(outside, "later"))
+	trashOnly(t, h)
+}
+
+func TestRenameError(t *testing.T) {
+	err := renameError(&os.LinkError{Op: "rename", Old: "/a/x", New: "/b/x", Err: syscall.EXDEV}, "x")
+	var we *writeError
+	if !errors.As(err, &we) || we.code != http.StatusConflict {
+		t.Errorf("cross-device rename: %v", err)
+	}
+
+	other := &os.LinkError{Op: "rename", Old: "/a/x", New: "/b/x", Err: syscall.EACCES}
+	if err := renameError(other, "x"); err != error(other) {
+		t.Errorf("other error became %v", err)
+	}
+	if err := renameError(nil, "x"); err != nil {
+		t.Errorf("success became %v", err)
+	}
+}
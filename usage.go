@@ -0,0 +1,152 @@
+package gosrvdir
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	usageTimeout    = 10 * time.Second
+	usageMaxEntries = 500_000
+	usageTopFiles   = 25
+)
+
+var errUsageLimit = errors.New("entry limit reached")
+
+// UsageItem is one bar on the disk usage page.
+type UsageItem struct {
+	Name  string
+	Path  string
+	Bytes int64
+	Files int64
+	IsDir bool
+}
+
+// UsageReport summarizes the space used below a directory.
+type UsageReport struct {
+	Total    int64
+	Files    int64
+	Children []UsageItem // immediate entries, largest first
+	Largest  []UsageItem // largest files anywhere below
+	Partial  bool        // cut short by usageTimeout, usageMaxEntries or errors
+	Elapsed  time.Duration
+}
+
+func (h *Handler) serveUsage(w http.ResponseWriter, r *http.Request, dirPath, urlPath string, settings *dirSettings) {
+	ctx, cancel := context.WithTimeout(r.Context(), usageTimeout)
+	defer cancel()
+
+	report := h.diskUsage(ctx, dirPath, urlPath, settings)
+	if r.Context().Err() != nil {
+		return
+	}
+
+	data := UsageData{
+		Path:   urlPath,
+		Theme:  settings.Theme,
+		Report: report,
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	UsagePage(data).Render(w)
+}
+
+// diskUsage walks a directory until done, cancelled, or usageMaxEntries
+// entries have been seen. Hidden and ignored entries are skipped, as are
+// subtrees protected by different credentials.
+func (h *Handler) diskUsage(ctx context.Context, dirPath, urlPath string, settings *dirSettings) UsageReport {
+	start := time.Now()
+	var report UsageReport
+	var seen int
+
+	var walk func(dir, urlDir string, s *dirSettings, child *UsageItem) error
+	walk = func(dir, urlDir string, s *dirSettings, child *UsageItem) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			report.Partial = true
+			return nil
+		}
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if seen++; seen > usageMaxEntries {
+				return errUsageLimit
+			}
+
+			name := entry.Name()
+			if s.hidden(name) {
+				continue
+			}
+			entryURL := path.Join(urlDir, name)
+
+			if entry.IsDir() {
+				sub, found, err := h.settingsFor(entryURL)
+				if err != nil || !found || !sameCreds(sub.Creds, settings.Creds) {
+					continue
+				}
+				target := child
+				if target == nil {
+					report.Children = append(report.Children, UsageItem{Name: name + "/", Path: entryURL + "/", IsDir: true})
+					target = &report.Children[len(report.Children)-1]
+				}
+				if err := walk(filepath.Join(dir, name), entryURL, &sub, target); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+
+			size := info.Size()
+			report.Total += size
+			report.Files++
+			item := UsageItem{Name: name, Path: entryURL, Bytes: size, Files: 1}
+			if child == nil {
+				report.Children = append(report.Children, item)
+			} else {
+				child.Bytes += size
+				child.Files++
+			}
+			report.Largest = insertLargest(report.Largest, item)
+		}
+		return nil
+	}
+
+	if err := walk(dirPath, urlPath, settings, nil); err != nil {
+		report.Partial = true
+	}
+
+	sort.Slice(report.Children, func(i, j int) bool {
+		return report.Children[i].Bytes > report.Children[j].Bytes
+	})
+	report.Elapsed = time.Since(start)
+	return report
+}
+
+// insertLargest keeps the usageTopFiles largest files, largest first.
+func insertLargest(top []UsageItem, item UsageItem) []UsageItem {
+	if len(top) == usageTopFiles && item.Bytes <= top[len(top)-1].Bytes {
+		return top
+	}
+	i := sort.Search(len(top), func(i int) bool { return top[i].Bytes < item.Bytes })
+	top = append(top, UsageItem{})
+	copy(top[i+1:], top[i:])
+	top[i] = item
+	if len(top) > usageTopFiles {
+		top = top[:usageTopFiles]
+	}
+	return top
+}
@@ -1,8 +1,13 @@
 package gosrvdir
 
 import (
+	"fmt"
 	"io"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	g "maragu.dev/gomponents"
 	c "maragu.dev/gomponents/components"
@@ -13,35 +18,532 @@ func RenderListing(w io.Writer, data ListingData) error {
 	return Page(data).Render(w)
 }
 
-func Page(data ListingData) g.Node {
-	title := data.Path
-	if title == "/" {
-		title = "/"
+// LayoutProps describes a page rendered inside the shared themed chrome.
+type LayoutProps struct {
+	Title   string
+	Theme   string
+	Head    []g.Node // extra nodes for <head>, e.g. page-specific styles
+	Nav     g.Node   // left side of the nav bar
+	Header  []g.Node
+	Main    []g.Node
+	Scripts []string
+}
+
+func Layout(p LayoutProps) g.Node {
+	head := []g.Node{
+		Meta(Name("viewport"), Content("width=device-width, initial-scale=1")),
+		g.El("style", g.Raw(cssStyles)),
+	}
+	head = append(head, p.Head...)
+
+	var scripts []g.Node
+	for _, js := range append([]string{jsThemeSwitcher}, p.Scripts...) {
+		scripts = append(scripts, g.El("script", g.Raw(js)))
+	}
+
+	nav := p.Nav
+	if nav == nil {
+		nav = Div()
 	}
 
 	return c.HTML5(c.HTML5Props{
-		Title:    title + " – gosrvdir",
+		Title:    p.Title + " – gosrvdir",
 		Language: "en",
-		Head: []g.Node{
-			Meta(Name("viewport"), Content("width=device-width, initial-scale=1")),
-			g.El("style", g.Raw(cssStyles)),
-		},
+		Head:     head,
 		Body: []g.Node{
-			g.Attr("data-theme", data.Theme),
+			g.Attr("data-theme", p.Theme),
 			Nav(
-				ThemeSwitcher(data.Theme),
+				nav,
+				ThemeSwitcher(p.Theme),
+			),
+			Header(p.Header...),
+			Main(p.Main...),
+			g.Group(scripts),
+		},
+	})
+}
+
+func Page(data ListingData) g.Node {
+	// Live updates would splice rows from other pages into this one
+	paged := data.Pages.Count() > 1
+	var scripts []string
+	if data.View == "grid" {
+		scripts = append(scripts, jsLightbox)
+	} else {
+		scripts = append(scripts, jsListingKeys)
+		if !paged {
+			scripts = append(scripts, jsLiveListing)
+		}
+		scripts = append(scripts, jsBulkSelect)
+	}
+
+	return Layout(LayoutProps{
+		Title: data.Path,
+		Theme: data.Theme,
+		Nav:   ViewSwitcher(data.View, data.HasMedia),
+		Header: []g.Node{
+			Breadcrumbs(data.Path),
+			g.If(data.Description != "", P(Class("description"), g.Text(data.Description))),
+		},
+		Main: []g.Node{
+			g.If(paged, PageNav(data.Path, data.Pages)),
+			g.If(data.View != "grid", BulkActions(data.CSRF, data.Trash)),
+			g.If(data.View != "grid", FileTable(data.Entries)),
+			g.If(data.View == "grid", Gallery(data.Entries)),
+			g.If(paged, PageNav(data.Path, data.Pages)),
+			ListingFooter(data.Path),
+			g.If(data.Readme != "", Readme(data.Readme)),
+			g.If(data.ReadmeHTML != "", Section(Class("readme markdown"), g.Raw(data.ReadmeHTML))),
+		},
+		Scripts: scripts,
+	})
+}
+
+type SourceData struct {
+	Path  string
+	Theme string
+	Code  string // highlighted HTML
+	Lines int
+	Size  string
+}
+
+func SourcePage(data SourceData) g.Node {
+	return Layout(LayoutProps{
+		Title: data.Path,
+		Theme: data.Theme,
+		Head:  []g.Node{g.El("style", g.Raw(chromaCSS()))},
+		Nav:   FileNav(data.Path),
+		Header: []g.Node{
+			Breadcrumbs(data.Path),
+		},
+		Main: []g.Node{
+			Div(Class("file-meta"),
+				Span(g.Textf("%d lines", data.Lines)),
+				Span(g.Text(data.Size)),
+			),
+			Div(Class("source"), g.Raw(data.Code)),
+		},
+		Scripts: []string{jsLineAnchors},
+	})
+}
+
+type MarkdownData struct {
+	Path  string
+	Theme string
+	HTML  string
+	TOC   []TOCEntry
+}
+
+func MarkdownPage(data MarkdownData) g.Node {
+	return Layout(LayoutProps{
+		Title: data.Path,
+		Theme: data.Theme,
+		Nav:   FileNav(data.Path),
+		Header: []g.Node{
+			Breadcrumbs(data.Path),
+		},
+		Main: []g.Node{
+			Div(Class("document"),
+				g.If(len(data.TOC) > 1, TableOfContents(data.TOC)),
+				Article(Class("markdown"), g.Raw(data.HTML)),
+			),
+		},
+	})
+}
+
+func TableOfContents(toc []TOCEntry) g.Node {
+	top := toc[0].Level
+	for _, e := range toc {
+		top = min(top, e.Level)
+	}
+
+	var items []g.Node
+	for _, e := range toc {
+		if e.Level > top+2 {
+			continue
+		}
+		items = append(items, Li(Class("toc-"+strconv.Itoa(e.Level-top)),
+			A(Href("#"+e.ID), g.Text(e.Title)),
+		))
+	}
+
+	return Aside(Class("toc"),
+		Div(Class("toc-title"), g.Text("Contents")),
+		Ul(g.Group(items)),
+	)
+}
+
+type TableData struct {
+	Path      string
+	Theme     string
+	Size      string
+	Header    []string
+	Rows      [][]string
+	Truncated bool
+}
+
+func TablePage(data TableData) g.Node {
+	var head []g.Node
+	for _, h := range data.Header {
+		head = append(head, Th(g.Text(h)))
+	}
+
+	var rows []g.Node
+	for _, row := range data.Rows {
+		var cells []g.Node
+		for _, cell := range row {
+			cells = append(cells, Td(g.Text(cell)))
+		}
+		rows = append(rows, Tr(cells...))
+	}
+
+	return Layout(LayoutProps{
+		Title: data.Path,
+		Theme: data.Theme,
+		Nav:   FileNav(data.Path),
+		Header: []g.Node{
+			Breadcrumbs(data.Path),
+		},
+		Main: []g.Node{
+			Div(Class("file-meta"),
+				Span(g.Textf("%d rows", len(data.Rows))),
+				g.If(data.Truncated, Span(g.Textf("showing the first %d rows", maxTableRows))),
+				Span(g.Text(data.Size)),
+			),
+			Div(Class("data-table"),
+				Table(
+					g.If(len(head) > 0, THead(Tr(head...))),
+					TBody(rows...),
+				),
+			),
+		},
+		Scripts: []string{jsSortTable},
+	})
+}
+
+type JSONData struct {
+	Path  string
+	Theme string
+	Size  string
+	Root  JSONValue
+}
+
+func JSONPage(data JSONData) g.Node {
+	return Layout(LayoutProps{
+		Title: data.Path,
+		Theme: data.Theme,
+		Nav:   FileNav(data.Path),
+		Header: []g.Node{
+			Breadcrumbs(data.Path),
+		},
+		Main: []g.Node{
+			Div(Class("file-meta"),
+				Span(g.Text(data.Size)),
+			),
+			Div(Class("json-tree"), JSONTree(data.Root, "", 0)),
+		},
+	})
+}
+
+// JSONTree renders a value as nested <details>, so it collapses without
+// JavaScript. The first two levels start expanded.
+func JSONTree(v JSONValue, key string, depth int) g.Node {
+	label := g.If(key != "", Span(Class("json-key"), g.Text(key+": ")))
+
+	switch v.Kind {
+	case "object", "array":
+		open, close := "{", "}"
+		if v.Kind == "array" {
+			open, close = "[", "]"
+		}
+
+		var items []g.Node
+		for i, item := range v.Items {
+			k := strconv.Itoa(i)
+			if v.Kind == "object" {
+				k = strconv.Quote(v.Keys[i])
+			}
+			items = append(items, Li(JSONTree(item, k, depth+1)))
+		}
+
+		return Details(g.If(depth < 2, g.Attr("open", "")),
+			Summary(label,
+				Span(Class("json-punct"), g.Text(open)),
+				Span(Class("json-count"), g.Textf(" %d items ", len(v.Items))),
+				Span(Class("json-punct"), g.Text(close)),
+			),
+			Ul(items...),
+		)
+	default:
+		return Div(Class("json-leaf"), label, Span(Class("json-"+v.Kind), g.Text(v.Scalar)))
+	}
+}
+
+type PlayerData struct {
+	Path     string
+	Theme    string
+	Items    []MediaItem
+	Playlist bool
+}
+
+func PlayerPage(data PlayerData) g.Node {
+	var nav g.Node = FileNav(data.Path)
+	if data.Playlist {
+		nav = Div(Class("view-switcher"),
+			A(Href("?view=list"), g.Text("List")),
+			A(Href("?format=m3u8"), g.Text("⬇ .m3u8")),
+		)
+	}
+
+	var player g.Node = P(Class("description"), g.Text("No audio or video files in this directory."))
+	if len(data.Items) > 0 {
+		first := data.Items[0]
+		if first.Video {
+			player = Video(ID("player"), Controls(), g.Attr("preload", "metadata"), Src(first.URL))
+		} else {
+			player = Audio(ID("player"), Controls(), g.Attr("preload", "metadata"), Src(first.URL))
+		}
+	}
+
+	var items []g.Node
+	for i, item := range data.Items {
+		icon := "🎵"
+		if item.Video {
+			icon = "🎬"
+		}
+		items = append(items, Li(
+			g.If(i == 0, Class("playing")),
+			A(Href(item.URL), g.Attr("data-video", strconv.FormatBool(item.Video)),
+				Span(Class("icon"), g.Text(icon)),
+				g.Text(item.Name),
+			),
+		))
+	}
+
+	return Layout(LayoutProps{
+		Title: data.Path,
+		Theme: data.Theme,
+		Nav:   nav,
+		Header: []g.Node{
+			Breadcrumbs(data.Path),
+		},
+		Main: []g.Node{
+			Div(Class("player"), player),
+			g.If(data.Playlist && len(items) > 0, Ol(Class("playlist"), g.Group(items))),
+		},
+		Scripts: []string{jsPlaylist},
+	})
+}
+
+func DetailsPage(data FileDetails) g.Node {
+	row := func(label string, value g.Node) g.Node {
+		return Tr(Th(g.Text(label)), Td(value))
+	}
+	text := func(s string) g.Node {
+		if s == "" {
+			return Span(Class("muted"), g.Text("—"))
+		}
+		return g.Text(s)
+	}
+
+	var hashes []g.Node
+	for _, res := range data.Hashes {
+		var value g.Node
+		switch {
+		case res.Err != "":
+			value = Span(Class("muted"), g.Text(res.Err))
+		case res.Sum != "":
+			value = Code(Class("hash"), g.Text(res.Sum))
+		default:
+			value = A(Class("button"), Href(href(data.Path, "info&hash="+res.Algo)), g.Text("Compute"))
+		}
+		hashes = append(hashes, row(strings.ToUpper(res.Algo), value))
+	}
+
+	return Layout(LayoutProps{
+		Title: data.Path,
+		Theme: data.Theme,
+		Nav:   FileNav(data.Path),
+		Header: []g.Node{
+			Breadcrumbs(data.Path),
+		},
+		Main: []g.Node{
+			Table(Class("details"),
+				TBody(
+					row("Name", text(data.Name)),
+					row("Size", g.Textf("%d bytes (%s)", data.Bytes, data.Size)),
+					row("Type", text(data.MIME)),
+					row("Modified", text(data.Modified)),
+					row("Permissions", Code(g.Text(data.Mode))),
+					row("Owner", text(data.Owner)),
+					row("Group", text(data.Group)),
+					g.Group(hashes),
+				),
+			),
+		},
+	})
+}
+
+type UsageData struct {
+	Path   string
+	Theme  string
+	Report UsageReport
+}
+
+func UsagePage(data UsageData) g.Node {
+	rep := data.Report
+
+	return Layout(LayoutProps{
+		Title: data.Path,
+		Theme: data.Theme,
+		Nav:   ViewSwitcher("usage", false),
+		Header: []g.Node{
+			Breadcrumbs(data.Path),
+		},
+		Main: []g.Node{
+			Div(Class("file-meta"),
+				Span(g.Textf("%s in %d files", formatSize(rep.Total), rep.Files)),
+				Span(g.Textf("scanned in %s", rep.Elapsed.Round(time.Millisecond))),
+				g.If(rep.Partial, Span(Class("warning"), g.Text("incomplete: the scan hit its time or entry limit"))),
+			),
+			H2(Class("usage-title"), g.Text("By entry")),
+			UsageBars(rep.Children, rep.Total),
+			H2(Class("usage-title"), g.Text("Largest files")),
+			UsageBars(rep.Largest, rep.Total),
+		},
+	})
+}
+
+// UsageBars renders items as horizontal bars scaled to the largest one,
+// labelled with their share of total.
+func UsageBars(items []UsageItem, total int64) g.Node {
+	if len(items) == 0 {
+		return P(Class("description"), g.Text("Nothing here."))
+	}
+
+	largest := max(items[0].Bytes, 1)
+	for _, it := range items {
+		largest = max(largest, it.Bytes)
+	}
+
+	var rows []g.Node
+	for _, it := range items {
+		width := float64(it.Bytes) / float64(largest) * 100
+		share := 0.0
+		if total > 0 {
+			share = float64(it.Bytes) / float64(total) * 100
+		}
+		link := href(it.Path, "")
+		if it.IsDir {
+			link = href(it.Path, "view=usage")
+		}
+
+		rows = append(rows, Div(Class("usage-row"),
+			A(Class("usage-name"), Href(link), g.Text(it.Name)),
+			Div(Class("usage-bar"),
+				Span(Class("usage-fill"), Style(fmt.Sprintf("width: %.1f%%", width))),
+			),
+			Span(Class("usage-size"), g.Textf("%s · %.1f%%", formatSize(it.Bytes), share)),
+		))
+	}
+
+	return Div(Class("usage"), g.Group(rows))
+}
+
+type TrashData struct {
+	Theme     string
+	CSRF      string
+	Retention time.Duration
+	Items     []TrashItem
+}
+
+// TrashPage lists deleted entries with buttons to restore or purge them.
+func TrashPage(data TrashData) g.Node {
+	token := Input(Type("hidden"), Name("csrf"), Value(data.CSRF))
+	action := func(query, label string, ids ...string) g.Node {
+		var fields []g.Node
+		for _, id := range ids {
+			fields = append(fields, Input(Type("hidden"), Name("id"), Value(id)))
+		}
+		return FormEl(Class("inline"), Method("post"), Action("?"+query),
+			token, g.Group(fields),
+			Button(Class("button"), Type("submit"), g.Text(label)),
+		)
+	}
+
+	var rows []g.Node
+	var all []string
+	for _, item := range data.Items {
+		all = append(all, item.ID)
+		name := path.Base(item.Path)
+		if item.IsDir {
+			name += "/"
+		}
+		parent := path.Dir(item.Path)
+		rows = append(rows, Tr(
+			Td(Class("name"),
+				Span(Class("icon"), g.If(item.IsDir, g.Text("📁")), g.If(!item.IsDir, g.Text(fileIcon(item.Path)))),
+				g.Text(name),
+				Div(Class("muted"), A(Href(strings.TrimSuffix(parent, "/")+"/"), g.Text(parent))),
+			),
+			Td(Class("size"), g.Text(item.Size)),
+			Td(Class("date"),
+				g.Text(item.Deleted.Local().Format("2006-01-02 15:04")),
+				g.If(item.User != "", Div(Class("muted"), g.Text("by "+item.User))),
+			),
+			Td(Class("actions"),
+				action("restore", "Restore", item.ID),
+				action("purge", "Delete", item.ID),
 			),
-			Header(
-				Breadcrumbs(data.Path),
+		))
+	}
+
+	return Layout(LayoutProps{
+		Title: "Trash",
+		Theme: data.Theme,
+		Header: []g.Node{
+			Div(Class("breadcrumbs"),
+				A(Class("crumb root"), Href("/"), g.Text("~")),
+				Span(Class("separator"), g.Text("/")),
+				Span(Class("crumb current"), g.Text("Trash")),
 			),
-			Main(
-				FileTable(data.Entries),
+		},
+		Main: []g.Node{
+			Div(Class("file-meta"),
+				Span(g.Textf("%d items", len(data.Items))),
+				Span(g.Textf("deleted after %s", formatRetention(data.Retention))),
+				g.If(len(all) > 0, action("purge", "Empty trash", all...)),
 			),
-			g.El("script", g.Raw(jsThemeSwitcher)),
+			g.If(len(rows) == 0, P(Class("description"), g.Text("The trash is empty."))),
+			g.If(len(rows) > 0, Table(
+				THead(Tr(
+					Th(Class("name"), g.Text("Name")),
+					Th(Class("size"), g.Text("Size")),
+					Th(Class("date"), g.Text("Deleted")),
+					Th(Class("actions")),
+				)),
+				TBody(g.Group(rows)),
+			)),
 		},
 	})
 }
 
+// formatRetention prints whole days as days, e.g. "30 days".
+func formatRetention(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+// FileNav links a file view back to the plain file and its details.
+func FileNav(path string) g.Node {
+	return Div(Class("view-switcher"),
+		A(Href(href(path, "")), g.Text("Raw")),
+		A(Href(href(path, "info")), g.Text("Info")),
+	)
+}
+
 func Breadcrumbs(path string) g.Node {
 	if path == "/" {
 		return Div(Class("breadcrumbs"),
@@ -79,16 +581,18 @@ func Breadcrumbs(path string) g.Node {
 	return Div(Class("breadcrumbs"), g.Group(crumbs))
 }
 
-func ThemeSwitcher(current string) g.Node {
-	themes := []struct{ value, label string }{
-		{"auto", "Auto"},
-		{"nord", "Nord"},
-		{"squirrel", "Squirrel"},
-		{"archlinux", "Archlinux"},
-		{"monokai", "Monokai"},
-		{"zenburn", "Zenburn"},
-	}
+type theme struct{ value, label string }
 
+var themes = []theme{
+	{"auto", "Auto"},
+	{"nord", "Nord"},
+	{"squirrel", "Squirrel"},
+	{"archlinux", "Archlinux"},
+	{"monokai", "Monokai"},
+	{"zenburn", "Zenburn"},
+}
+
+func ThemeSwitcher(current string) g.Node {
 	var options []g.Node
 	for _, t := range themes {
 		opt := Option(Value(t.value), g.Text(t.label))
@@ -108,41 +612,197 @@ func ThemeSwitcher(current string) g.Node {
 	)
 }
 
-func FileTable(entries []FileInfo) g.Node {
-	var rows []g.Node
+func ViewSwitcher(current string, hasMedia bool) g.Node {
+	link := func(view, label string) g.Node {
+		active := view == current || (view == "list" && current == "")
+		return A(Href("?view="+view), g.If(active, Class("active")), g.Text(label))
+	}
+
+	return Div(Class("view-switcher"),
+		link("list", "List"),
+		link("grid", "Grid"),
+		link("usage", "Usage"),
+		g.If(hasMedia, link("playlist", "▶ Play all")),
+	)
+}
+
+// href builds an escaped link to a listing path with an optional query.
+func href(p, query string) string {
+	u := url.URL{Path: p, RawQuery: query}
+	return u.String()
+}
+
+func Gallery(entries []FileInfo) g.Node {
+	var tiles []g.Node
 
 	for _, entry := range entries {
-		var icon, class string
-		if entry.Name == ".." {
-			icon = "⬆️"
-			class = "name parent"
-		} else if entry.IsDir {
-			icon = "📁"
-			class = "name dir"
-		} else {
-			icon = fileIcon(entry.Name)
-			class = "name file"
+		switch {
+		case entry.IsDir:
+			icon := "📁"
+			if entry.Name == ".." {
+				icon = "⬆️"
+			}
+			tiles = append(tiles, A(Class("tile dir"), Href(href(entry.Path, "view=grid")),
+				Span(Class("tile-icon"), g.Text(icon)),
+				Span(Class("caption"), g.Text(entry.Name)),
+			))
+		case isImage(entry.Name):
+			src := href(entry.Path, "")
+			if thumbnailable(entry.Name) {
+				src = href(entry.Path, "view=thumb")
+			}
+			tiles = append(tiles, A(Class("tile image"), Href(href(entry.Path, "")), g.Attr("data-lightbox", ""),
+				Img(Src(src), Alt(entry.Name), g.Attr("loading", "lazy")),
+				Span(Class("caption"), g.Text(entry.Name)),
+			))
+		default:
+			tiles = append(tiles, A(Class("tile file"), Href(href(entry.Path, "")),
+				Span(Class("tile-icon"), g.Text(fileIcon(entry.Name))),
+				Span(Class("caption"), g.Text(entry.Name)),
+			))
 		}
-
-		rows = append(rows, Tr(
-			Td(Class(class),
-				Span(Class("icon"), g.Text(icon)),
-				A(Href(entry.Path), g.Text(entry.Name)),
-			),
-			Td(Class("size"), g.Text(entry.Size)),
-			Td(Class("date"), g.Text(entry.ModTime)),
-		))
 	}
 
+	return g.Group([]g.Node{
+		Div(Class("gallery"), g.Group(tiles)),
+		Div(ID("lightbox"), g.Attr("hidden", ""),
+			Button(Class("lb-close"), g.Attr("aria-label", "Close"), g.Text("✕")),
+			Button(Class("lb-prev"), g.Attr("aria-label", "Previous"), g.Text("‹")),
+			Img(Alt("")),
+			Button(Class("lb-next"), g.Attr("aria-label", "Next"), g.Text("›")),
+			Div(Class("lb-caption")),
+		),
+	})
+}
+
+func FileTable(entries []FileInfo) g.Node {
+	// Rows are rendered straight to the response instead of being built
+	// up as one tree first
+	rows := g.NodeFunc(func(w io.Writer) error {
+		for _, entry := range entries {
+			if err := FileRow(entry).Render(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
 	return Table(
 		THead(
 			Tr(
+				Th(Class("select"), Input(Type("checkbox"), Class("select-all"), g.Attr("aria-label", "Select all"), g.Attr("hidden", ""))),
 				Th(Class("name"), g.Text("Name")),
 				Th(Class("size"), g.Text("Size")),
 				Th(Class("date"), g.Text("Modified")),
 			),
 		),
-		TBody(g.Group(rows)),
+		TBody(rows),
+	)
+}
+
+// FileRow is one FileTable row; live listings replace rows by data-name.
+func FileRow(entry FileInfo) g.Node {
+	var icon, class string
+	if entry.Name == ".." {
+		icon = "⬆️"
+		class = "name parent"
+	} else if entry.IsDir {
+		icon = "📁"
+		class = "name dir"
+	} else {
+		icon = fileIcon(entry.Name)
+		class = "name file"
+	}
+
+	link := entry.Path
+	if entry.View != "" {
+		link = href(entry.Path, "view="+entry.View)
+	}
+
+	return Tr(Data("name", entry.Name),
+		Td(Class("select"),
+			g.If(entry.Name != "..", Input(Type("checkbox"), Name("name"), Value(entry.Name), g.Attr("form", "bulk"), g.Attr("aria-label", "Select "+entry.Name))),
+		),
+		Td(Class(class),
+			Span(Class("icon"), g.Text(icon)),
+			A(Href(link), g.Text(entry.Name)),
+			g.If(!entry.IsDir, A(Class("info"), Href(href(entry.Path, "info")), Title("Details and checksums"), g.Text("ⓘ"))),
+		),
+		Td(Class("size"),
+			g.If(entry.IsDir && entry.Size != "", Title(strconv.FormatInt(entry.Files, 10)+" files")),
+			g.Text(entry.Size),
+		),
+		Td(Class("date"), g.Text(entry.ModTime)),
+	)
+}
+
+// BulkActions is the form the FileTable checkboxes belong to. With a
+// CSRF token it adds the file management actions.
+func BulkActions(csrf string, trash bool) g.Node {
+	token := Input(Type("hidden"), Name("csrf"), Value(csrf))
+
+	return g.Group([]g.Node{
+		FormEl(ID("bulk"), Class("bulk"), Method("post"), Action("?zip"),
+			Button(Type("submit"), g.Text("⬇ Download selected (zip)")),
+			g.If(csrf != "", g.Group([]g.Node{
+				token,
+				Button(Type("submit"), Class("delete"), g.Attr("formaction", "?delete"), g.Text("🗑 Delete")),
+				Input(Type("text"), Name("to"), Placeholder("new name"), g.Attr("aria-label", "New name")),
+				Button(Type("submit"), g.Attr("formaction", "?rename"), g.Text("Rename")),
+				Input(Type("text"), Name("dest"), Placeholder("/target/dir/"), g.Attr("aria-label", "Move to directory")),
+				Button(Type("submit"), g.Attr("formaction", "?move"), g.Text("Move")),
+			})),
+			Span(Class("bulk-count")),
+		),
+		g.If(csrf != "", FormEl(Class("bulk"), Method("post"), Action("?mkdir"),
+			token,
+			Input(Type("text"), Name("name"), Placeholder("folder name"), Required(), g.Attr("aria-label", "New folder name")),
+			Button(Type("submit"), g.Text("📁 New folder")),
+			g.If(trash, A(Class("button"), Href(reservedPrefix+"trash"), g.Text("🗑 Trash"))),
+		)),
+	})
+}
+
+// PageNav links to the neighbouring pages of a paginated listing.
+func PageNav(path string, p Pagination) g.Node {
+	link := func(page int, label string) g.Node {
+		if page < 1 || page > p.Count() || page == p.Page {
+			return Span(Class("disabled"), g.Text(label))
+		}
+		q := url.Values{}
+		for key, values := range p.Query {
+			q[key] = values
+		}
+		if page > 1 {
+			q.Set("page", strconv.Itoa(page))
+		}
+		return A(Href(href(path, q.Encode())), g.Text(label))
+	}
+
+	first := (p.Page-1)*p.PerPage + 1
+	last := min(p.Page*p.PerPage, p.Total)
+
+	return Div(Class("pages"),
+		link(1, "« First"),
+		link(p.Page-1, "‹ Prev"),
+		Span(Class("page-info"), g.Textf("%d–%d of %d · page %d of %d", first, last, p.Total, p.Page, p.Count())),
+		link(p.Page+1, "Next ›"),
+		link(p.Count(), "Last »"),
+	)
+}
+
+func ListingFooter(path string) g.Node {
+	return Div(Class("listing-footer"),
+		g.Text("Checksums: "),
+		A(Href(href(path+ManifestName, "")), g.Text(ManifestName)),
+		g.Text(" · "),
+		A(Href(href(path, "manifest=json")), g.Text("JSON")),
+	)
+}
+
+func Readme(text string) g.Node {
+	return Section(Class("readme"),
+		Pre(g.Text(text)),
 	)
 }
 
@@ -184,6 +844,11 @@ func fileIcon(name string) string {
 		strings.HasSuffix(lower, ".fish"):
 		return "📜"
 
+	// Tabular data
+	case strings.HasSuffix(lower, ".csv"),
+		strings.HasSuffix(lower, ".tsv"):
+		return "📊"
+
 	// Config/Data
 	case strings.HasSuffix(lower, ".json"),
 		strings.HasSuffix(lower, ".yaml"),
@@ -368,12 +1033,31 @@ body {
 
 nav {
   display: flex;
-  justify-content: flex-end;
+  justify-content: space-between;
+  align-items: center;
   padding: 0.75rem 1.5rem;
   border-bottom: 1px solid var(--border);
   background: var(--bg-card);
 }
 
+.view-switcher {
+  display: flex;
+  gap: 0.25rem;
+  font-size: 0.85rem;
+}
+
+.view-switcher a {
+  color: var(--text-muted);
+  padding: 0.3rem 0.6rem;
+  border: 1px solid var(--border);
+  border-radius: 4px;
+}
+
+.view-switcher a.active {
+  color: var(--accent);
+  border-color: var(--accent);
+}
+
 .theme-switcher {
   display: flex;
   align-items: center;
@@ -392,33 +1076,97 @@ select {
   cursor: pointer;
 }
 
-select:focus {
+input.filter {
+  width: 100%;
+  margin-bottom: 0.75rem;
+  background: var(--select-bg);
+  color: var(--text);
+  border: 1px solid var(--border);
+  padding: 0.45rem 0.75rem;
+  border-radius: 4px;
+  font-size: 0.9rem;
+}
+
+input.filter:focus {
   outline: 2px solid var(--accent);
   outline-offset: 1px;
 }
 
-header {
-  padding: 1rem 1.5rem;
-  background: var(--bg-card);
-  border-bottom: 1px solid var(--border);
+th.select, td.select {
+  width: 2rem;
+  padding-right: 0;
 }
 
-.breadcrumbs {
+.bulk {
   display: flex;
   align-items: center;
-  flex-wrap: wrap;
-  gap: 0.25rem;
-  font-size: 1.1rem;
-}
-
-.crumb {
-  color: var(--link-dir);
-  text-decoration: none;
-  font-weight: 500;
+  gap: 0.75rem;
+  margin-bottom: 0.75rem;
+  font-size: 0.85rem;
+  color: var(--text-muted);
 }
 
-a.crumb:hover {
-  text-decoration: underline;
+.bulk button {
+  background: var(--select-bg);
+  color: var(--text);
+  border: 1px solid var(--border);
+  padding: 0.35rem 0.75rem;
+  border-radius: 4px;
+  font-size: 0.85rem;
+  cursor: pointer;
+}
+
+.bulk input[type=text] {
+  background: var(--select-bg);
+  color: var(--text);
+  border: 1px solid var(--border);
+  padding: 0.35rem 0.5rem;
+  border-radius: 4px;
+  font-size: 0.85rem;
+  width: 10rem;
+}
+
+.bulk button:disabled {
+  opacity: 0.5;
+  cursor: default;
+}
+
+tbody tr.selected td {
+  background: var(--row-hover);
+  box-shadow: inset 3px 0 var(--accent);
+}
+
+tbody tr.selected td + td {
+  box-shadow: none;
+}
+
+select:focus {
+  outline: 2px solid var(--accent);
+  outline-offset: 1px;
+}
+
+header {
+  padding: 1rem 1.5rem;
+  background: var(--bg-card);
+  border-bottom: 1px solid var(--border);
+}
+
+.breadcrumbs {
+  display: flex;
+  align-items: center;
+  flex-wrap: wrap;
+  gap: 0.25rem;
+  font-size: 1.1rem;
+}
+
+.crumb {
+  color: var(--link-dir);
+  text-decoration: none;
+  font-weight: 500;
+}
+
+a.crumb:hover {
+  text-decoration: underline;
 }
 
 .crumb.root {
@@ -435,10 +1183,508 @@ a.crumb:hover {
   margin: 0 0.15rem;
 }
 
+.description {
+  margin: 0.4rem 0 0;
+  color: var(--text-muted);
+  font-size: 0.95rem;
+}
+
 main {
   padding: 1rem 1.5rem 2rem;
 }
 
+.gallery {
+  display: grid;
+  grid-template-columns: repeat(auto-fill, minmax(160px, 1fr));
+  gap: 0.75rem;
+}
+
+.tile {
+  display: flex;
+  flex-direction: column;
+  align-items: center;
+  justify-content: flex-end;
+  gap: 0.4rem;
+  padding: 0.5rem;
+  min-height: 160px;
+  background: var(--bg-card);
+  border: 1px solid var(--border);
+  border-radius: 8px;
+  color: var(--link-file);
+  overflow: hidden;
+}
+
+.tile:hover {
+  background: var(--row-hover);
+}
+
+.tile.dir {
+  color: var(--link-dir);
+}
+
+.tile img {
+  max-width: 100%;
+  max-height: 130px;
+  object-fit: contain;
+  margin: auto 0;
+}
+
+.tile-icon {
+  font-size: 2.5rem;
+  margin: auto 0;
+}
+
+.caption {
+  font-size: 0.8rem;
+  max-width: 100%;
+  white-space: nowrap;
+  overflow: hidden;
+  text-overflow: ellipsis;
+}
+
+#lightbox {
+  position: fixed;
+  inset: 0;
+  z-index: 10;
+  display: flex;
+  align-items: center;
+  justify-content: center;
+  background: rgba(0,0,0,0.88);
+}
+
+#lightbox[hidden] {
+  display: none;
+}
+
+#lightbox img {
+  max-width: 90vw;
+  max-height: 85vh;
+  object-fit: contain;
+}
+
+#lightbox button {
+  position: absolute;
+  background: none;
+  border: none;
+  color: #fff;
+  font-size: 2.5rem;
+  cursor: pointer;
+  padding: 1rem;
+}
+
+.lb-close { top: 0; right: 0; font-size: 1.5rem !important; }
+.lb-prev { left: 0; }
+.lb-next { right: 0; }
+
+.lb-caption {
+  position: absolute;
+  bottom: 1rem;
+  color: #ddd;
+  font-size: 0.9rem;
+}
+
+.file-meta {
+  display: flex;
+  gap: 1rem;
+  margin-bottom: 0.75rem;
+  font-size: 0.85rem;
+  color: var(--text-muted);
+}
+
+.source {
+  border: 1px solid var(--border);
+  border-radius: 8px;
+  overflow: auto;
+}
+
+.source pre {
+  margin: 0;
+  padding: 0.75rem 0;
+  font-size: 0.85rem;
+  line-height: 1.5;
+}
+
+.source .line {
+  padding-right: 1rem;
+}
+
+.source .ln {
+  min-width: 3.5em;
+  text-align: right;
+}
+
+.source .ln a:hover {
+  text-decoration: underline;
+}
+
+.document {
+  display: flex;
+  gap: 1.5rem;
+  align-items: flex-start;
+}
+
+.toc {
+  position: sticky;
+  top: 1rem;
+  flex: 0 0 14rem;
+  max-height: calc(100vh - 2rem);
+  overflow-y: auto;
+  font-size: 0.85rem;
+}
+
+.toc-title {
+  font-weight: 600;
+  text-transform: uppercase;
+  letter-spacing: 0.03em;
+  color: var(--text-muted);
+  margin-bottom: 0.5rem;
+}
+
+.toc ul {
+  list-style: none;
+  margin: 0;
+  padding: 0;
+}
+
+.toc li { margin: 0.2rem 0; }
+.toc .toc-1 { padding-left: 0.75rem; }
+.toc .toc-2 { padding-left: 1.5rem; }
+
+.toc a {
+  color: var(--link-file);
+}
+
+.markdown {
+  flex: 1;
+  min-width: 0;
+  padding: 1rem 2rem;
+  background: var(--bg-card);
+  border: 1px solid var(--border);
+  border-radius: 8px;
+}
+
+.markdown a {
+  color: var(--link-dir);
+}
+
+.markdown a:hover {
+  text-decoration: underline;
+}
+
+.markdown img {
+  max-width: 100%;
+}
+
+.markdown pre, .markdown code {
+  background: var(--header-bg);
+  border-radius: 4px;
+  font-size: 0.9em;
+}
+
+.markdown code {
+  padding: 0.1em 0.3em;
+}
+
+.markdown pre {
+  padding: 0.75rem 1rem;
+  overflow-x: auto;
+}
+
+.markdown pre code {
+  padding: 0;
+  background: none;
+}
+
+.markdown blockquote {
+  margin: 0;
+  padding-left: 1rem;
+  border-left: 3px solid var(--border);
+  color: var(--text-muted);
+}
+
+.markdown table {
+  width: auto;
+  box-shadow: none;
+  border-radius: 0;
+}
+
+.markdown th, .markdown td {
+  border: 1px solid var(--border);
+  padding: 0.4rem 0.75rem;
+  text-transform: none;
+  letter-spacing: normal;
+}
+
+.data-table {
+  overflow-x: auto;
+  border-radius: 8px;
+}
+
+.data-table td, .data-table th {
+  white-space: nowrap;
+  width: auto;
+}
+
+.data-table th {
+  cursor: pointer;
+  user-select: none;
+}
+
+.data-table th[aria-sort="ascending"]::after { content: " ▲"; }
+.data-table th[aria-sort="descending"]::after { content: " ▼"; }
+
+.json-tree {
+  padding: 1rem 1.5rem;
+  background: var(--bg-card);
+  border: 1px solid var(--border);
+  border-radius: 8px;
+  font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace;
+  font-size: 0.85rem;
+  overflow-x: auto;
+}
+
+.json-tree ul {
+  list-style: none;
+  margin: 0;
+  padding-left: 1.5rem;
+  border-left: 1px dotted var(--border);
+}
+
+.json-tree summary {
+  cursor: pointer;
+}
+
+.json-tree details[open] > summary .json-count {
+  display: none;
+}
+
+.json-key { color: var(--link-dir); }
+.json-string { color: var(--link-file); }
+.json-number, .json-bool, .json-null { color: var(--accent); }
+.json-punct, .json-count { color: var(--text-muted); }
+
+.player {
+  display: flex;
+  justify-content: center;
+  margin-bottom: 1rem;
+}
+
+.player video {
+  max-width: 100%;
+  max-height: 70vh;
+  background: #000;
+  border-radius: 8px;
+}
+
+.player audio {
+  width: 100%;
+  max-width: 40rem;
+}
+
+.playlist {
+  list-style: none;
+  margin: 0 auto;
+  padding: 0;
+  max-width: 40rem;
+  background: var(--bg-card);
+  border: 1px solid var(--border);
+  border-radius: 8px;
+  overflow: hidden;
+}
+
+.playlist li a {
+  display: block;
+  padding: 0.5rem 1rem;
+  color: var(--link-file);
+  border-bottom: 1px solid var(--border);
+}
+
+.playlist li:last-child a {
+  border-bottom: none;
+}
+
+.playlist li a:hover {
+  background: var(--row-hover);
+}
+
+.playlist li.playing a {
+  color: var(--accent);
+  font-weight: 600;
+}
+
+.info {
+  margin-left: 0.5rem;
+  color: var(--text-muted) !important;
+  font-weight: normal;
+  opacity: 0;
+}
+
+tr:hover .info, .info:focus {
+  opacity: 1;
+}
+
+tbody tr.changed td {
+  animation: changed 2s ease-out;
+}
+
+@keyframes changed {
+  from { background: var(--row-hover); box-shadow: inset 3px 0 var(--accent); }
+}
+
+.details {
+  max-width: 50rem;
+}
+
+.details th {
+  width: 10rem;
+  text-transform: none;
+  letter-spacing: normal;
+  font-size: 0.9rem;
+  border-bottom: 1px solid var(--border);
+}
+
+.details td {
+  width: auto;
+  word-break: break-all;
+}
+
+.hash {
+  font-size: 0.85rem;
+  user-select: all;
+}
+
+form.inline {
+  display: inline;
+  margin-left: 0.25rem;
+}
+
+form.inline button {
+  background: none;
+  cursor: pointer;
+}
+
+td.actions {
+  text-align: right;
+  white-space: nowrap;
+}
+
+.muted {
+  color: var(--text-muted);
+}
+
+.button {
+  display: inline-block;
+  padding: 0.2rem 0.7rem;
+  border: 1px solid var(--accent);
+  border-radius: 4px;
+  color: var(--accent);
+  font-size: 0.85rem;
+}
+
+.button:hover {
+  background: var(--row-hover);
+}
+
+.pages {
+  display: flex;
+  justify-content: center;
+  align-items: center;
+  gap: 1rem;
+  margin: 0.75rem 0;
+  font-size: 0.85rem;
+}
+
+.pages a {
+  color: var(--accent);
+  text-decoration: none;
+}
+
+.pages .disabled, .pages .page-info {
+  color: var(--text-muted);
+}
+
+.listing-footer {
+  margin-top: 0.5rem;
+  font-size: 0.8rem;
+  color: var(--text-muted);
+  text-align: right;
+}
+
+.listing-footer a {
+  color: var(--text-muted);
+  text-decoration: underline;
+}
+
+.usage-title {
+  font-size: 0.8rem;
+  font-weight: 600;
+  text-transform: uppercase;
+  letter-spacing: 0.03em;
+  color: var(--text-muted);
+  margin: 1.5rem 0 0.5rem;
+}
+
+.usage {
+  background: var(--bg-card);
+  border: 1px solid var(--border);
+  border-radius: 8px;
+  padding: 0.5rem 1rem;
+}
+
+.usage-row {
+  display: grid;
+  grid-template-columns: minmax(8rem, 2fr) 3fr minmax(7rem, auto);
+  align-items: center;
+  gap: 1rem;
+  padding: 0.3rem 0;
+}
+
+.usage-name {
+  color: var(--link-file);
+  overflow: hidden;
+  text-overflow: ellipsis;
+  white-space: nowrap;
+}
+
+.usage-bar {
+  height: 0.8rem;
+  background: var(--header-bg);
+  border-radius: 4px;
+  overflow: hidden;
+}
+
+.usage-fill {
+  display: block;
+  height: 100%;
+  background: var(--accent);
+}
+
+.usage-size {
+  font-size: 0.85rem;
+  color: var(--text-muted);
+  text-align: right;
+  font-variant-numeric: tabular-nums;
+}
+
+.warning {
+  color: var(--accent);
+}
+
+.readme {
+  margin-top: 1.5rem;
+  padding: 1rem 1.5rem;
+  background: var(--bg-card);
+  border: 1px solid var(--border);
+  border-radius: 8px;
+}
+
+.readme pre {
+  margin: 0;
+  white-space: pre-wrap;
+  word-wrap: break-word;
+  font-size: 0.9rem;
+}
+
 table {
   width: 100%;
   border-collapse: collapse;
@@ -544,6 +1790,19 @@ td a:hover {
     display: none;
   }
 
+  .document {
+    flex-direction: column;
+  }
+
+  .toc {
+    position: static;
+    flex: none;
+  }
+
+  .markdown {
+    padding: 0.5rem 1rem;
+  }
+
   .name {
     width: 70%;
   }
@@ -575,3 +1834,332 @@ function setTheme(theme) {
   }
 })();
 `
+
+const jsListingKeys = `
+(function() {
+  const table = document.querySelector('main table');
+  if (!table || !table.tBodies[0]) return;
+  const tbody = table.tBodies[0];
+  let selected = null;
+
+  const box = document.createElement('input');
+  box.type = 'search';
+  box.className = 'filter';
+  box.placeholder = 'Filter (press /)';
+  box.setAttribute('aria-label', 'Filter entries');
+  table.parentElement.insertBefore(box, table);
+
+  function visible() {
+    return Array.from(tbody.rows).filter(function(r) { return !r.hidden; });
+  }
+
+  function select(row) {
+    if (selected) selected.classList.remove('selected');
+    selected = row;
+    if (row) {
+      row.classList.add('selected');
+      row.scrollIntoView({block: 'nearest'});
+    }
+  }
+
+  function filter() {
+    const q = box.value.trim().toLowerCase();
+    Array.from(tbody.rows).forEach(function(r) {
+      const name = r.dataset.name || '';
+      r.hidden = q !== '' && name !== '..' && name.toLowerCase().indexOf(q) < 0;
+    });
+    if (selected && selected.hidden) select(null);
+  }
+
+  function move(step) {
+    const rows = visible();
+    if (rows.length === 0) return;
+    const i = rows.indexOf(selected);
+    select(rows[Math.max(0, Math.min(rows.length - 1, i < 0 ? 0 : i + step))]);
+  }
+
+  box.addEventListener('input', filter);
+  box.addEventListener('keydown', function(e) {
+    if (e.key === 'Escape') {
+      box.value = '';
+      filter();
+      box.blur();
+    } else if (e.key === 'ArrowDown' || e.key === 'Enter') {
+      e.preventDefault();
+      box.blur();
+      if (e.key === 'Enter' && visible().length > 0) {
+        const rows = visible();
+        select(rows.find(function(r) { return r.dataset.name !== '..'; }) || rows[0]);
+      } else {
+        move(1);
+      }
+    }
+  });
+
+  // Rows added by live updates honour the current filter
+  new MutationObserver(filter).observe(tbody, {childList: true});
+
+  document.addEventListener('keydown', function(e) {
+    const t = e.target;
+    if (e.ctrlKey || e.metaKey || e.altKey) return;
+    if (t.tagName === 'INPUT' || t.tagName === 'SELECT' || t.tagName === 'TEXTAREA' || t.isContentEditable) return;
+
+    if (e.key === '/') {
+      box.focus();
+    } else if (e.key === 'j' || e.key === 'ArrowDown') {
+      move(1);
+    } else if (e.key === 'k' || e.key === 'ArrowUp') {
+      move(-1);
+    } else if (e.key === 'Enter' && selected) {
+      selected.querySelector('a').click();
+    } else if (e.key === 'x' && selected) {
+      const box = selected.querySelector('input[type=checkbox]');
+      if (box) {
+        box.checked = !box.checked;
+        box.dispatchEvent(new Event('change', {bubbles: true}));
+      }
+    } else if (e.key === 'Backspace') {
+      const up = tbody.querySelector('tr[data-name=".."] a');
+      if (up) up.click();
+    } else {
+      return;
+    }
+    e.preventDefault();
+  });
+})();
+`
+
+const jsLightbox = `
+(function() {
+  const box = document.getElementById('lightbox');
+  const links = Array.from(document.querySelectorAll('a[data-lightbox]'));
+  if (!box || links.length === 0) return;
+  const img = box.querySelector('img');
+  const caption = box.querySelector('.lb-caption');
+  let current = -1;
+
+  function show(i) {
+    current = (i + links.length) % links.length;
+    img.src = links[current].href;
+    caption.textContent = links[current].querySelector('img').alt;
+    box.hidden = false;
+  }
+
+  function close() {
+    box.hidden = true;
+    img.removeAttribute('src');
+    if (links[current]) links[current].focus();
+  }
+
+  links.forEach(function(a, i) {
+    a.addEventListener('click', function(e) {
+      e.preventDefault();
+      show(i);
+    });
+  });
+
+  box.querySelector('.lb-prev').addEventListener('click', function() { show(current - 1); });
+  box.querySelector('.lb-next').addEventListener('click', function() { show(current + 1); });
+  box.querySelector('.lb-close').addEventListener('click', close);
+  box.addEventListener('click', function(e) { if (e.target === box) close(); });
+
+  document.addEventListener('keydown', function(e) {
+    if (box.hidden) return;
+    if (e.key === 'Escape') close();
+    else if (e.key === 'ArrowLeft') show(current - 1);
+    else if (e.key === 'ArrowRight') show(current + 1);
+  });
+})();
+`
+
+const jsLineAnchors = `
+(function() {
+  let anchor = 0;
+
+  function highlight(scroll) {
+    document.querySelectorAll('.source .line.hl').forEach(function(el) {
+      el.classList.remove('hl');
+    });
+    const m = location.hash.match(/^#L(\d+)(?:-L(\d+))?$/);
+    if (!m) return;
+    let from = parseInt(m[1], 10), to = m[2] ? parseInt(m[2], 10) : from;
+    if (from > to) { const t = from; from = to; to = t; }
+    for (let i = from; i <= to; i++) {
+      const ln = document.getElementById('L' + i);
+      if (ln) ln.parentElement.classList.add('hl');
+    }
+    const first = document.getElementById('L' + from);
+    if (first && scroll) first.scrollIntoView({block: 'center'});
+  }
+
+  // Shift-click a line number to select a range
+  document.querySelectorAll('.source .lnlinks').forEach(function(a) {
+    a.addEventListener('click', function(e) {
+      e.preventDefault();
+      const line = parseInt(a.textContent, 10);
+      if (e.shiftKey && anchor) {
+        history.replaceState(null, '', '#L' + Math.min(anchor, line) + '-L' + Math.max(anchor, line));
+      } else {
+        anchor = line;
+        history.replaceState(null, '', '#L' + line);
+      }
+      highlight(false);
+    });
+  });
+
+  window.addEventListener('hashchange', function() { highlight(true); });
+  highlight(true);
+})();
+`
+
+const jsSortTable = `
+(function() {
+  const table = document.querySelector('.data-table table');
+  if (!table || !table.tHead) return;
+  const tbody = table.tBodies[0];
+
+  function value(row, i) {
+    const cell = row.cells[i];
+    return cell ? cell.textContent.trim() : '';
+  }
+
+  Array.from(table.tHead.rows[0].cells).forEach(function(th, i) {
+    th.addEventListener('click', function() {
+      const asc = th.getAttribute('aria-sort') !== 'ascending';
+      Array.from(th.parentElement.cells).forEach(function(c) { c.removeAttribute('aria-sort'); });
+      th.setAttribute('aria-sort', asc ? 'ascending' : 'descending');
+
+      const rows = Array.from(tbody.rows);
+      rows.sort(function(a, b) {
+        const x = value(a, i), y = value(b, i);
+        const nx = x === '' ? NaN : Number(x), ny = y === '' ? NaN : Number(y);
+        const cmp = !isNaN(nx) && !isNaN(ny)
+          ? nx - ny
+          : x.localeCompare(y, undefined, {numeric: true, sensitivity: 'base'});
+        return asc ? cmp : -cmp;
+      });
+      rows.forEach(function(r) { tbody.appendChild(r); });
+    });
+  });
+})();
+`
+
+const jsPlaylist = `
+(function() {
+  let player = document.getElementById('player');
+  const links = Array.from(document.querySelectorAll('.playlist a'));
+  if (!player || links.length === 0) return;
+  let current = 0;
+
+  function play(i) {
+    if (i < 0 || i >= links.length) return;
+    current = i;
+    const a = links[i];
+    const tag = a.dataset.video === 'true' ? 'VIDEO' : 'AUDIO';
+    if (player.tagName !== tag) {
+      const el = document.createElement(tag.toLowerCase());
+      el.id = 'player';
+      el.controls = true;
+      player.replaceWith(el);
+      player = el;
+      player.addEventListener('ended', next);
+    }
+    player.src = a.href;
+    player.play();
+    links.forEach(function(l, j) { l.parentElement.classList.toggle('playing', j === i); });
+  }
+
+  function next() { play(current + 1); }
+
+  links.forEach(function(a, i) {
+    a.addEventListener('click', function(e) {
+      e.preventDefault();
+      play(i);
+    });
+  });
+  player.addEventListener('ended', next);
+})();
+`
+
+const jsLiveListing = `
+(function() {
+  const tbody = document.querySelector('main table tbody');
+  if (!tbody || !window.EventSource) return;
+
+  function row(name) {
+    return Array.from(tbody.rows).find(function(r) { return r.dataset.name === name; });
+  }
+
+  function parse(html) {
+    const t = document.createElement('tbody');
+    t.innerHTML = html;
+    const r = t.firstElementChild;
+    r.classList.add('changed');
+    return r;
+  }
+
+  const es = new EventSource('?events');
+  es.addEventListener('create', function(e) {
+    const d = JSON.parse(e.data);
+    const old = row(d.name);
+    if (old) old.remove();
+    tbody.insertBefore(parse(d.html), (d.before && row(d.before)) || null);
+  });
+  es.addEventListener('modify', function(e) {
+    const d = JSON.parse(e.data);
+    const old = row(d.name);
+    if (old) old.replaceWith(parse(d.html));
+  });
+  es.addEventListener('delete', function(e) {
+    const old = row(JSON.parse(e.data).name);
+    if (old) old.remove();
+  });
+})();
+`
+
+const jsLiveReload = `
+(function() {
+  if (!window.EventSource) return;
+  const es = new EventSource('/_gosrvdir/livereload');
+  es.addEventListener('reload', function() { location.reload(); });
+})();
+`
+
+const jsBulkSelect = `
+(function() {
+  const form = document.getElementById('bulk');
+  const all = document.querySelector('main table .select-all');
+  if (!form || !all) return;
+  const buttons = form.querySelectorAll('button');
+  const count = form.querySelector('.bulk-count');
+  all.hidden = false;
+
+  const del = form.querySelector('button.delete');
+  if (del) del.addEventListener('click', function(e) {
+    const n = boxes().filter(function(b) { return b.checked; }).length;
+    if (!confirm('Delete ' + n + ' selected entr' + (n === 1 ? 'y' : 'ies') + '?')) e.preventDefault();
+  });
+
+  function boxes() {
+    return Array.from(document.querySelectorAll('main tbody input[form="bulk"]'));
+  }
+
+  function update() {
+    const n = boxes().filter(function(b) { return b.checked; }).length;
+    buttons.forEach(function(b) { b.disabled = n === 0; });
+    count.textContent = n ? n + ' selected' : '';
+  }
+
+  // Select all applies to the rows the filter leaves visible
+  all.addEventListener('change', function() {
+    boxes().forEach(function(b) {
+      if (!b.closest('tr').hidden) b.checked = all.checked;
+    });
+    update();
+  });
+  document.addEventListener('change', function(e) {
+    if (e.target.form === form) update();
+  });
+  update();
+})();
+`
@@ -0,0 +1,261 @@
+package gosrvdir
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	watchDebounce = 250 * time.Millisecond // coalesce bursts of changes
+	watchPoll     = 2 * time.Second        // interval of the polling fallback
+)
+
+// watchDir reports batches of entry names that changed in dir until ctx is
+// done. It uses fsnotify (inotify on Linux) and falls back to polling where
+// that is unavailable, e.g. on network filesystems or exhausted watch limits.
+func watchDir(ctx context.Context, dir string) <-chan []string {
+	raw := make(chan string)
+	out := make(chan []string)
+
+	w, err := fsnotify.NewWatcher()
+	if err == nil {
+		if err = w.Add(dir); err != nil {
+			w.Close()
+		}
+	}
+	if err == nil {
+		go notifyDir(ctx, w, raw)
+	} else {
+		go pollDir(ctx, dir, raw)
+	}
+	go debounce(ctx, raw, out)
+	return out
+}
+
+func notifyDir(ctx context.Context, w *fsnotify.Watcher, raw chan<- string) {
+	defer w.Close()
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			select {
+			case raw <- filepath.Base(ev.Name):
+			case <-ctx.Done():
+				return
+			}
+		case _, ok := <-w.Errors:
+			// Overflows only lose events; later changes still arrive
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+type pollState struct {
+	size  int64
+	mod   time.Time
+	isDir bool
+}
+
+func pollDir(ctx context.Context, dir string, raw chan<- string) {
+	prev := snapshotDir(dir)
+	ticker := time.NewTicker(watchPoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		cur := snapshotDir(dir)
+		var changed []string
+		for name, st := range cur {
+			if old, ok := prev[name]; !ok || old != st {
+				changed = append(changed, name)
+			}
+		}
+		for name := range prev {
+			if _, ok := cur[name]; !ok {
+				changed = append(changed, name)
+			}
+		}
+		prev = cur
+
+		for _, name := range changed {
+			select {
+			case raw <- name:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func snapshotDir(dir string) map[string]pollState {
+	entries, _ := os.ReadDir(dir)
+	snap := make(map[string]pollState, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snap[entry.Name()] = pollState{size: info.Size(), mod: info.ModTime(), isDir: entry.IsDir()}
+	}
+	return snap
+}
+
+// debounce collects names for watchDebounce after the first change, so a
+// burst of writes becomes one update.
+func debounce(ctx context.Context, in <-chan string, out chan<- []string) {
+	defer close(out)
+	pending := make(map[string]bool)
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case name := <-in:
+			pending[name] = true
+			if timer == nil {
+				timer = time.After(watchDebounce)
+			}
+		case <-timer:
+			names := slices.Sorted(maps.Keys(pending))
+			clear(pending)
+			timer = nil
+			select {
+			case out <- names:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchTree reports changes anywhere below root until ctx is done, one
+// value per filesystem event (or per poll that found changes). Dot
+// directories such as .git are skipped.
+func watchTree(ctx context.Context, root string) <-chan struct{} {
+	out := make(chan struct{})
+
+	w, err := fsnotify.NewWatcher()
+	if err == nil {
+		if err = addTree(w, root); err != nil {
+			w.Close()
+		}
+	}
+	if err == nil {
+		go notifyTree(ctx, w, out)
+	} else {
+		go pollTree(ctx, root, out)
+	}
+	return out
+}
+
+// addTree watches root and every directory below it.
+func addTree(w *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if p != root && strings.HasPrefix(entry.Name(), ".") {
+			return fs.SkipDir
+		}
+		return w.Add(p)
+	})
+}
+
+func notifyTree(ctx context.Context, w *fsnotify.Watcher, out chan<- struct{}) {
+	defer w.Close()
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if strings.HasPrefix(filepath.Base(ev.Name), ".") {
+				continue
+			}
+			// New directories need watches of their own
+			if ev.Has(fsnotify.Create) {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					addTree(w, ev.Name)
+				}
+			}
+			select {
+			case out <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		case _, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func pollTree(ctx context.Context, root string, out chan<- struct{}) {
+	prev := treeSignature(root)
+	ticker := time.NewTicker(watchPoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		if cur := treeSignature(root); cur != prev {
+			prev = cur
+			select {
+			case out <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// treeSignature hashes the names, sizes and mtimes below root.
+func treeSignature(root string) uint64 {
+	h := fnv.New64a()
+	filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p != root && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", p, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return h.Sum64()
+}
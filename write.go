@@ -0,0 +1,312 @@
+package gosrvdir
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+type userKey struct{}
+
+// withUser records the user who passed Basic auth for this request.
+func withUser(r *http.Request, user string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+}
+
+// authUser is the authenticated user, or "" when no credentials were
+// checked (no auth configured, or an allowlisted client skipped it).
+func authUser(r *http.Request) string {
+	user, _ := r.Context().Value(userKey{}).(string)
+	return user
+}
+
+// writeUser returns the request's user if they may modify files.
+func (h *Handler) writeUser(r *http.Request) (string, bool) {
+	user := authUser(r)
+	return user, user != "" && slices.Contains(h.WriteUsers, user)
+}
+
+// csrfKey signs per-user form tokens. The zero value is ready to use;
+// the key is random per process, so tokens expire on restart.
+type csrfKey struct {
+	once sync.Once
+	key  []byte
+}
+
+func (k *csrfKey) token(user string) string {
+	k.once.Do(func() {
+		k.key = make([]byte, 32)
+		rand.Read(k.key)
+	})
+	mac := hmac.New(sha256.New, k.key)
+	mac.Write([]byte(user))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (k *csrfKey) valid(user, token string) bool {
+	return hmac.Equal([]byte(k.token(user)), []byte(token))
+}
+
+// writeError is a failed file operation with the status to report.
+type writeError struct {
+	code int
+	msg  string
+}
+
+func (e *writeError) Error() string { return e.msg }
+
+func badRequest(msg string) error { return &writeError{http.StatusBadRequest, msg} }
+
+// serveWrite handles the ?mkdir, ?rename, ?move and ?delete POSTs of a
+// directory and redirects back to its listing.
+func (h *Handler) serveWrite(w http.ResponseWriter, r *http.Request, dirPath, urlPath string, settings *dirSettings) {
+	user, ok := h.writeUser(r)
+	if !ok {
+		http.Error(w, "Write access denied", http.StatusForbidden)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !sameOrigin(r) || !h.csrf.valid(user, r.PostForm.Get("csrf")) {
+		http.Error(w, "Invalid or expired form token, reload the page", http.StatusForbidden)
+		return
+	}
+
+	// Work on where the directory really is, so that entries below it are
+	// judged by their own settings rather than those of a symlink's path
+	realURL, ok := h.rootPath(dirPath)
+	if !ok {
+		http.Error(w, "Cannot write there", http.StatusForbidden)
+		return
+	}
+	realDir := filepath.Join(h.Dir, filepath.FromSlash(realURL))
+
+	var err error
+	q := r.URL.Query()
+	switch {
+	case q.Has("mkdir"):
+		err = h.mkdir(realDir, settings, r.PostForm.Get("name"))
+	case q.Has("rename"):
+		err = h.rename(realDir, realURL, settings, r.PostForm["name"], r.PostForm.Get("to"))
+	case q.Has("move"):
+		err = h.move(realDir, urlPath, realURL, settings, r.PostForm["name"], r.PostForm.Get("dest"))
+	case q.Has("delete"):
+		err = h.remove(realDir, realURL, settings, r.PostForm["name"], user)
+	}
+	if err != nil {
+		var we *writeError
+		if errors.As(err, &we) {
+			http.Error(w, we.msg, we.code)
+		} else {
+			log.Printf("write by %s in %s: %v", user, urlPath, err)
+			http.Error(w, "Operation failed", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("%s: %s in %s: %s", user, r.URL.RawQuery, urlPath, strings.Join(r.PostForm["name"], ", "))
+	http.Redirect(w, r, href(strings.TrimSuffix(urlPath, "/")+"/", ""), http.StatusSeeOther)
+}
+
+// insideRoot reports whether p, with symlinks resolved, is Dir or below
+// it. A path that does not exist yet is judged by its nearest existing
+// parent; a dangling symlink is not inside.
+func (h *Handler) insideRoot(p string) bool {
+	_, ok := h.rootPath(p)
+	return ok
+}
+
+// sameOrigin rejects cross-site form posts from browsers that send Origin.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// entryName validates a name from a form: a single path element that is
+// visible in the directory (or may become so, for new names).
+func entryName(name string, settings *dirSettings) (string, error) {
+	name = strings.TrimSuffix(name, "/")
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") || settings.hidden(name) {
+		return "", badRequest("Invalid name")
+	}
+	return name, nil
+}
+
+func (h *Handler) mkdir(dirPath string, settings *dirSettings, name string) error {
+	name, err := entryName(name, settings)
+	if err != nil {
+		return err
+	}
+	if err := os.Mkdir(filepath.Join(dirPath, name), 0o755); errors.Is(err, fs.ErrExist) {
+		return &writeError{http.StatusConflict, name + " already exists"}
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (h *Handler) rename(dirPath, urlPath string, settings *dirSettings, names []string, to string) error {
+	if len(names) != 1 {
+		return badRequest("Select exactly one entry to rename")
+	}
+	from, err := entryName(names[0], settings)
+	if err != nil {
+		return err
+	}
+	to, err = entryName(to, settings)
+	if err != nil {
+		return err
+	}
+	src, err := h.existingEntry(dirPath, urlPath, settings, from)
+	if err != nil {
+		return err
+	}
+	return renameNoReplace(src, filepath.Join(dirPath, to))
+}
+
+// move moves entries of dirPath, which is really at realURL, to the
+// directory dest, a listing URL or one relative to urlPath.
+func (h *Handler) move(dirPath, urlPath, realURL string, settings *dirSettings, names []string, dest string) error {
+	if len(names) == 0 {
+		return badRequest("Nothing selected")
+	}
+
+	// The destination is a listing URL, e.g. "/other/dir/", or relative to this one
+	if !strings.HasPrefix(dest, "/") {
+		dest = path.Join(urlPath, dest)
+	}
+	destPath := filepath.Join(h.Dir, filepath.FromSlash(path.Clean(dest)))
+	if info, err := os.Stat(destPath); err != nil || !info.IsDir() {
+		return badRequest("Destination is not a directory")
+	}
+	// Judge the destination by where it really is, not by a symlink's path
+	dest, ok := h.rootPath(destPath)
+	if !ok {
+		return &writeError{http.StatusForbidden, "Cannot move there"}
+	}
+	destPath = filepath.Join(h.Dir, filepath.FromSlash(dest))
+	destSettings, found, err := h.settingsFor(dest)
+	if err != nil {
+		return err
+	}
+	if !found || !sameCreds(destSettings.Creds, settings.Creds) {
+		return &writeError{http.StatusForbidden, "Cannot move there"}
+	}
+
+	for _, name := range names {
+		name, err := entryName(name, settings)
+		if err != nil {
+			return err
+		}
+		if destSettings.hidden(name) {
+			return badRequest(name + " would be hidden at the destination")
+		}
+		src, err := h.existingEntry(dirPath, realURL, settings, name)
+		if err != nil {
+			return err
+		}
+		if destPath == src || strings.HasPrefix(destPath, src+string(filepath.Separator)) {
+			return badRequest("Cannot move " + name + " into itself")
+		}
+		if err := renameNoReplace(src, filepath.Join(destPath, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove moves entries to the trash, or deletes them for good when
+// TrashRetention is zero.
+func (h *Handler) remove(dirPath, urlPath string, settings *dirSettings, names []string, user string) error {
+	if len(names) == 0 {
+		return badRequest("Nothing selected")
+	}
+	for _, name := range names {
+		name, err := entryName(name, settings)
+		if err != nil {
+			return err
+		}
+		p, err := h.existingEntry(dirPath, urlPath, settings, name)
+		if err != nil {
+			return err
+		}
+		if h.TrashRetention > 0 {
+			err = h.moveToTrash(p, path.Join(urlPath, name), user)
+		} else {
+			err = os.RemoveAll(p)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// existingEntry resolves a name in the directory at dirPath, which must
+// be where urlPath really is. Directories that are (or contain) subtrees
+// with other credentials cannot be changed here.
+func (h *Handler) existingEntry(dirPath, urlPath string, settings *dirSettings, name string) (string, error) {
+	p := filepath.Join(dirPath, name)
+	info, err := os.Lstat(p)
+	if err != nil {
+		return "", &writeError{http.StatusNotFound, name + " not found"}
+	}
+	if info.IsDir() && !h.sameCredsBelow(p, path.Join(urlPath, name), settings) {
+		return "", &writeError{http.StatusForbidden, name + " contains protected directories"}
+	}
+	return p, nil
+}
+
+func (h *Handler) sameCredsBelow(dirPath, urlPath string, settings *dirSettings) bool {
+	same := true
+	filepath.WalkDir(dirPath, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dirPath, p)
+		sub, _, err := h.settingsFor(path.Join(urlPath, filepath.ToSlash(rel)))
+		if err != nil || !sameCreds(sub.Creds, settings.Creds) {
+			same = false
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return same
+}
+
+// renameNoReplace renames src to dst unless dst already exists.
+func renameNoReplace(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return &writeError{http.StatusConflict, filepath.Base(dst) + " already exists"}
+	}
+	return renameError(os.Rename(src, dst), filepath.Base(src))
+}
+
+// renameError explains a rename that failed because it would cross
+// filesystems, which only a copy could do.
+func renameError(err error, name string) error {
+	if errors.Is(err, syscall.EXDEV) {
+		return &writeError{http.StatusConflict, "Cannot move " + name + " to another filesystem"}
+	}
+	return err
+}
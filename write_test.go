@@ -0,0 +1,263 @@
+package gosrvdir
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeHandler serves a tree that alice may change and bob may only
+// read, with priv/ (and a shortcut into it from sub/) reserved to carol:
+//
+//	a.txt, sub/b.txt, priv/p.txt, priv/deep/d.txt,
+//	sub/shortcut -> ../priv/deep, alias -> sub, esc -> a directory outside
+func writeHandler(t *testing.T) (*Handler, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	outside := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.txt":                 "a",
+		"sub/b.txt":             "b",
+		"priv/p.txt":            "private",
+		"priv/deep/d.txt":       "d",
+		"priv/.htpasswd":        "carol:" + hashPassword(t, "pw") + "\n",
+		"priv/" + DirConfigName: "auth_file = \".htpasswd\"\n",
+		"a b#c/x.txt":           "x",
+	})
+	for link, target := range map[string]string{
+		"sub/shortcut": "../priv/deep",
+		"alias":        "sub",
+		"esc":          outside,
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(link))); err != nil {
+			t.Skip("symlinks not supported:", err)
+		}
+	}
+
+	h := &Handler{
+		Dir: dir,
+		Creds: Credentials{
+			"alice": hashPassword(t, "pw"),
+			"bob":   hashPassword(t, "pw"),
+		},
+		WriteUsers: []string{"alice"},
+	}
+	return h, dir, outside
+}
+
+// postWrite sends a write form to the directory urlPath as user, adding
+// that user's CSRF token unless the form has one. headers are name/value
+// pairs.
+func postWrite(h *Handler, urlPath, op, user string, form url.Values, headers ...string) *httptest.ResponseRecorder {
+	if !form.Has("csrf") {
+		form.Set("csrf", h.csrf.token(user))
+	}
+	r := httptest.NewRequest(http.MethodPost, urlPath+"?"+op, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth(user, "pw")
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestEntryName(t *testing.T) {
+	settings := &dirSettings{ShowHidden: true, Hide: []string{"*.bak"}, Ignore: []string{"*.tmp"}}
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"file.txt", "file.txt", true},
+		{"folder/", "folder", true},
+		{"with space", "with space", true},
+		{".profile", ".profile", true},
+		{"", "", false},
+		{"/", "", false},
+		{".", "", false},
+		{"..", "", false},
+		{"../x", "", false},
+		{"a/b", "", false},
+		{`a\b`, "", false},
+		{"nul\x00", "", false},
+		{DirConfigName, "", false},
+		{TrashName, "", false},
+		{"old.bak", "", false},
+		{"cache.tmp", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := entryName(tt.name, settings)
+			if (err == nil) != tt.ok || got != tt.want {
+				t.Errorf("entryName(%q) = %q, %v", tt.name, got, err)
+			}
+		})
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	var k csrfKey
+	token := k.token("alice")
+	if token != k.token("alice") {
+		t.Error("token changes between calls")
+	}
+	if token == k.token("bob") {
+		t.Error("alice and bob share a token")
+	}
+	if !k.valid("alice", token) {
+		t.Error("own token rejected")
+	}
+	for _, bad := range []string{"", k.token("bob"), strings.ToUpper(token), token[:len(token)-1]} {
+		if k.valid("alice", bad) {
+			t.Errorf("token %q accepted for alice", bad)
+		}
+	}
+
+	// Each process has its own key
+	var other csrfKey
+	if other.valid("alice", token) {
+		t.Error("token valid under another key")
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"http://example.com", true},
+		{"https://example.com", true},
+		{"http://example.com:8080", false},
+		{"http://evil.example", false},
+		{"null", false},
+		{"://bad", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://example.com/", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := sameOrigin(r); got != tt.ok {
+				t.Errorf("sameOrigin with Origin %q = %v", tt.origin, got)
+			}
+		})
+	}
+}
+
+func TestServeWrite(t *testing.T) {
+	h, dir, _ := writeHandler(t)
+
+	rec := postWrite(h, "/", "mkdir", "alice", url.Values{"name": {"new"}})
+	expectStatus(t, rec, http.StatusSeeOther)
+	if loc := rec.Header().Get("Location"); loc != "/" {
+		t.Errorf("redirect to %q", loc)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "new")); err != nil || !info.IsDir() {
+		t.Errorf("new not created: %v", err)
+	}
+	expectStatus(t, postWrite(h, "/", "mkdir", "alice", url.Values{"name": {"new"}}), http.StatusConflict)
+	expectStatus(t, postWrite(h, "/", "mkdir", "alice", url.Values{"name": {DirConfigName}}), http.StatusBadRequest)
+
+	// The redirect back to the listing is a properly escaped URL
+	rec = postWrite(h, "/a%20b%23c/", "mkdir", "alice", url.Values{"name": {"y"}})
+	expectStatus(t, rec, http.StatusSeeOther)
+	if loc := rec.Header().Get("Location"); loc != "/a%20b%23c/" {
+		t.Errorf("redirect to %q", loc)
+	}
+
+	expectStatus(t, postWrite(h, "/", "rename", "alice", url.Values{"name": {"a.txt"}, "to": {"renamed.txt"}}), http.StatusSeeOther)
+	expectFile(t, filepath.Join(dir, "renamed.txt"), "a")
+	expectMissing(t, filepath.Join(dir, "a.txt"))
+	expectStatus(t, postWrite(h, "/", "rename", "alice", url.Values{"name": {"renamed.txt", "sub"}, "to": {"x"}}), http.StatusBadRequest)
+	expectStatus(t, postWrite(h, "/", "rename", "alice", url.Values{"name": {"missing"}, "to": {"x"}}), http.StatusNotFound)
+	expectStatus(t, postWrite(h, "/", "rename", "alice", url.Values{"name": {"renamed.txt"}, "to": {"sub"}}), http.StatusConflict)
+
+	expectStatus(t, postWrite(h, "/", "move", "alice", url.Values{"name": {"renamed.txt"}, "dest": {"sub"}}), http.StatusSeeOther)
+	expectFile(t, filepath.Join(dir, "sub", "renamed.txt"), "a")
+	expectStatus(t, postWrite(h, "/sub/", "move", "alice", url.Values{"name": {"renamed.txt"}, "dest": {"/new/"}}), http.StatusSeeOther)
+	expectFile(t, filepath.Join(dir, "new", "renamed.txt"), "a")
+	expectStatus(t, postWrite(h, "/", "move", "alice", url.Values{"name": {"sub"}, "dest": {"/sub/"}}), http.StatusBadRequest)
+	expectStatus(t, postWrite(h, "/", "move", "alice", url.Values{"name": {"new"}, "dest": {"/missing/"}}), http.StatusBadRequest)
+	expectStatus(t, postWrite(h, "/", "move", "alice", url.Values{"name": {"new"}, "dest": {"/priv/"}}), http.StatusForbidden)
+
+	expectStatus(t, postWrite(h, "/", "delete", "alice", url.Values{"name": {"new"}}), http.StatusSeeOther)
+	expectMissing(t, filepath.Join(dir, "new"))
+	// priv/ cannot be removed by those who cannot see into it
+	expectStatus(t, postWrite(h, "/", "delete", "alice", url.Values{"name": {"priv"}}), http.StatusForbidden)
+	expectFile(t, filepath.Join(dir, "priv", "p.txt"), "private")
+}
+
+func TestServeWriteDenied(t *testing.T) {
+	h, dir, _ := writeHandler(t)
+	expectStatus(t, postWrite(h, "/", "mkdir", "bob", url.Values{"name": {"new"}}), http.StatusForbidden)
+	expectStatus(t, postWrite(h, "/", "mkdir", "alice", url.Values{"name": {"new"}, "csrf": {""}}), http.StatusForbidden)
+	expectStatus(t, postWrite(h, "/", "mkdir", "alice", url.Values{"name": {"new"}, "csrf": {h.csrf.token("bob")}}), http.StatusForbidden)
+	expectStatus(t, postWrite(h, "/", "mkdir", "alice", url.Values{"name": {"new"}}, "Origin", "http://evil.example"), http.StatusForbidden)
+	expectStatus(t, postWrite(h, "/", "mkdir", "alice", url.Values{"name": {"new"}}, "Origin", "http://example.com"), http.StatusSeeOther)
+	expectStatus(t, postWrite(h, "/", "delete", "alice", url.Values{"name": {"new"}}), http.StatusSeeOther)
+
+	// Without auth there is no user who could be allowed to write
+	open := &Handler{Dir: dir, WriteUsers: []string{"alice"}}
+	expectStatus(t, postWrite(open, "/", "mkdir", "alice", url.Values{"name": {"new"}}), http.StatusForbidden)
+
+	expectMissing(t, filepath.Join(dir, "new"))
+}
+
+func TestServeWriteSymlinks(t *testing.T) {
+	h, dir, outside := writeHandler(t)
+
+	// A link into priv/ gets priv's credentials, as a listing or as a destination
+	expectStatus(t, postWrite(h, "/", "move", "alice", url.Values{"name": {"a.txt"}, "dest": {"/sub/shortcut/"}}), http.StatusForbidden)
+	expectStatus(t, postWrite(h, "/sub/", "move", "alice", url.Values{"name": {"b.txt"}, "dest": {"shortcut"}}), http.StatusForbidden)
+	expectStatus(t, postWrite(h, "/alias/", "move", "alice", url.Values{"name": {"b.txt"}, "dest": {"shortcut"}}), http.StatusForbidden)
+	expectStatus(t, postWrite(h, "/sub/shortcut/", "delete", "alice", url.Values{"name": {"d.txt"}}), http.StatusUnauthorized)
+	expectFile(t, filepath.Join(dir, "a.txt"), "a")
+	expectFile(t, filepath.Join(dir, "sub", "b.txt"), "b")
+	expectFile(t, filepath.Join(dir, "priv", "deep", "d.txt"), "d")
+
+	// Nothing is written through a link out of the root
+	expectStatus(t, postWrite(h, "/esc/", "mkdir", "alice", url.Values{"name": {"new"}}), http.StatusForbidden)
+	expectStatus(t, postWrite(h, "/", "move", "alice", url.Values{"name": {"a.txt"}, "dest": {"/esc/"}}), http.StatusForbidden)
+	expectMissing(t, filepath.Join(outside, "new"))
+	expectMissing(t, filepath.Join(outside, "a.txt"))
+
+	// Moving a directory into a link to itself is still moving it into itself
+	expectStatus(t, postWrite(h, "/", "move", "alice", url.Values{"name": {"sub"}, "dest": {"/alias/"}}), http.StatusBadRequest)
+
+	// The link itself is an entry like any other
+	rec := postWrite(h, "/alias/", "move", "alice", url.Values{"name": {"b.txt"}, "dest": {"/"}})
+	expectStatus(t, rec, http.StatusSeeOther)
+	if loc := rec.Header().Get("Location"); loc != "/alias/" {
+		t.Errorf("redirect to %q", loc)
+	}
+	expectFile(t, filepath.Join(dir, "b.txt"), "b")
+	expectStatus(t, postWrite(h, "/", "rename", "alice", url.Values{"name": {"esc"}, "to": {"gone"}}), http.StatusSeeOther)
+	if target, err := os.Readlink(filepath.Join(dir, "gone")); err != nil || target != outside {
+		t.Errorf("renamed link = %q, %v", target, err)
+	}
+}
+
+func TestServeWriteTrash(t *testing.T) {
+	h, dir, _ := writeHandler(t)
+	h.TrashRetention = time.Hour
+
+	// Deleting through a link records where the entry really was
+	expectStatus(t, postWrite(h, "/alias/", "delete", "alice", url.Values{"name": {"b.txt"}}), http.StatusSeeOther)
+	expectMissing(t, filepath.Join(dir, "sub", "b.txt"))
+	items, err := h.trashItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Path != "/sub/b.txt" {
+		t.Errorf("trash = %+v", items)
+	}
+}
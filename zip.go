@@ -0,0 +1,114 @@
+package gosrvdir
+
+import (
+	"archive/zip"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// serveZip answers a POST of selected entry names ("name" form fields)
+// with a zip of those files and, recursively, directories. Names must be
+// visible entries of this directory; subtrees with their own credentials
+// and symlinks are left out.
+func (h *Handler) serveZip(w http.ResponseWriter, r *http.Request, dirPath, urlPath string, settings *dirSettings) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	names := slices.Compact(slices.Sorted(slices.Values(r.PostForm["name"])))
+	if len(names) == 0 {
+		http.Error(w, "Nothing selected", http.StatusBadRequest)
+		return
+	}
+
+	var files []manifestFile
+	for _, name := range names {
+		name = strings.TrimSuffix(name, "/")
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || settings.hidden(name) {
+			http.Error(w, "Invalid selection", http.StatusBadRequest)
+			return
+		}
+
+		abs := filepath.Join(dirPath, name)
+		info, err := os.Lstat(abs)
+		if err != nil {
+			http.Error(w, "Not Found: "+name, http.StatusNotFound)
+			return
+		}
+
+		switch {
+		case info.IsDir():
+			sub, found, err := h.settingsFor(path.Join(urlPath, name))
+			if err != nil || !found || !sameCreds(sub.Creds, settings.Creds) {
+				continue
+			}
+			below, err := h.collectManifestFiles(abs, path.Join(urlPath, name), &sub, true)
+			if err != nil {
+				http.Error(w, "Cannot read directory", http.StatusInternalServerError)
+				return
+			}
+			for _, f := range below {
+				f.rel = path.Join(name, f.rel)
+				files = append(files, f)
+			}
+		case info.Mode().IsRegular():
+			files = append(files, manifestFile{rel: name, abs: abs, info: info})
+		}
+	}
+
+	if h.Metrics != nil {
+		h.Metrics.activeDownloads.Add(1)
+		defer h.Metrics.activeDownloads.Add(-1)
+	}
+
+	archive := path.Base(urlPath)
+	if archive == "/" || archive == "." {
+		archive = "download"
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archive + ".zip"}))
+
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+	for _, f := range files {
+		if r.Context().Err() != nil {
+			return
+		}
+		if err := addZipFile(zw, f); err != nil {
+			// Headers are sent; all we can do is cut the archive short
+			return
+		}
+	}
+}
+
+func addZipFile(zw *zip.Writer, f manifestFile) error {
+	header, err := zip.FileInfoHeader(f.info)
+	if err != nil {
+		return err
+	}
+	header.Name = f.rel
+	header.Method = zip.Store
+	// Media and archives are already compressed
+	if compressible(mime.TypeByExtension(path.Ext(f.rel))) {
+		header.Method = zip.Deflate
+	}
+
+	src, err := os.Open(f.abs)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
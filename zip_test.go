@@ -0,0 +1,132 @@
+package gosrvdir
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// postZip asks h for a zip of names in the directory urlPath.
+func postZip(h *Handler, urlPath string, names ...string) *httptest.ResponseRecorder {
+	form := url.Values{"name": names}
+	r := httptest.NewRequest(http.MethodPost, urlPath+"?zip", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+// readZip returns the contents of the archive in rec by entry name.
+func readZip(t *testing.T, rec *httptest.ResponseRecorder) map[string]string {
+	t.Helper()
+	body := rec.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(b)
+	}
+	return files
+}
+
+func TestZip(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.txt":                                 "a",
+		"docs/b.txt":                            "b",
+		"docs/.secret":                          "hidden",
+		"docs/skip.tmp":                         "ignored",
+		"docs/draft.txt":                        "hidden",
+		"docs/deep/.secret":                     "hidden",
+		"docs/" + DirConfigName:                 "show_hidden = false\nhide = [\"draft*\"]\nignore = [\"*.tmp\"]\n",
+		"docs/deep/c.txt":                       "c",
+		"priv/" + DirConfigName:                 "auth_file = \".htpasswd\"\n",
+		"priv/.htpasswd":                        "carol:" + hashPassword(t, "pw") + "\n",
+		"priv/p.txt":                            "private",
+		"docs/nested/" + TrashName + "/old.txt": "trashed",
+	})
+	writeTree(t, outside, map[string]string{"o.txt": "outside"})
+	for link, target := range map[string]string{
+		"link.txt":      "a.txt",
+		"linkdir":       "docs",
+		"out":           outside,
+		"docs/up.txt":   "../a.txt",
+		"docs/outlink":  outside,
+		"docs/privlink": "../priv",
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(link))); err != nil {
+			t.Skip("symlinks not supported:", err)
+		}
+	}
+	h := &Handler{Dir: dir}
+
+	rec := postZip(h, "/", "a.txt", "docs", "priv", "link.txt", "linkdir", "out")
+	expectStatus(t, rec, http.StatusOK)
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename=download.zip` {
+		t.Errorf("Content-Disposition %q", cd)
+	}
+	files := readZip(t, rec)
+	want := map[string]string{"a.txt": "a", "docs/b.txt": "b", "docs/deep/c.txt": "c"}
+	for name, content := range want {
+		if files[name] != content {
+			t.Errorf("%s = %q, want %q", name, files[name], content)
+		}
+	}
+	for name := range files {
+		if _, ok := want[name]; !ok {
+			t.Errorf("unexpected entry %s", name)
+		}
+		if slices.Contains(strings.Split(name, "/"), "..") || strings.HasPrefix(name, "/") {
+			t.Errorf("entry %s leaves the archive root", name)
+		}
+	}
+
+	// The selection is named relative to the directory it was posted to
+	files = readZip(t, postZip(h, "/docs/", "deep"))
+	if len(files) != 1 || files["deep/c.txt"] != "c" {
+		t.Errorf("docs/deep archive = %v", files)
+	}
+
+	for _, tt := range []struct {
+		name string
+		code int
+	}{
+		{"..", http.StatusBadRequest},
+		{"../a.txt", http.StatusBadRequest},
+		{"docs/b.txt", http.StatusBadRequest},
+		{`docs\b.txt`, http.StatusBadRequest},
+		{".", http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+		{DirConfigName, http.StatusBadRequest},
+		{"missing.txt", http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			expectStatus(t, postZip(h, "/", tt.name), tt.code)
+		})
+	}
+	// Hidden and ignored names cannot be selected either
+	expectStatus(t, postZip(h, "/docs/", ".secret"), http.StatusBadRequest)
+	expectStatus(t, postZip(h, "/docs/", "skip.tmp"), http.StatusBadRequest)
+	expectStatus(t, postZip(h, "/docs/", "draft.txt"), http.StatusBadRequest)
+	expectStatus(t, postZip(h, "/"), http.StatusBadRequest)
+}